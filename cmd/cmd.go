@@ -22,6 +22,7 @@ func GnoplsCmd() *cobra.Command {
 				GNOROOT: os.Getenv("GNOROOT"),
 				GNOHOME: env.GnoHome(),
 			}
+			redirectStdout()
 			err := lsp.RunServer(cmd.Context(), procEnv)
 			if err != nil {
 				return err
@@ -44,3 +45,10 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+// redirectStdout sends os.Stdout to stderr, the server connection writes
+// to env.Stdout and the gno preprocessor prints its stack to os.Stdout
+// when it fails.
+func redirectStdout() {
+	os.Stdout = os.Stderr
+}
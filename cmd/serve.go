@@ -24,6 +24,7 @@ func CmdServe() *cobra.Command {
 			if env.GNOROOT == "" {
 				env.GNOROOT = os.Getenv("GNOROOT")
 			}
+			redirectStdout()
 			err := lsp.RunServer(cmd.Context(), env)
 			if err != nil {
 				return err
@@ -1,47 +1,46 @@
 package builtin
 
 import (
+	"sort"
 	"strings"
 
+	"github.com/gnolang/gnopls/internal/fuzzy"
 	"go.lsp.dev/protocol"
 )
 
 //go:generate go run ../../tools/codegen-builtins -src ../../tools/gendata/builtin.go.txt -dest ./builtin_gen.go -omit Type,Type1,IntegerType,FloatType,ComplexType
 
-// GetCompletions provides list of builtin symbols that has passed prefix in a name.
-func GetCompletions(prefix string) []protocol.CompletionItem {
-	prefix = strings.TrimSpace(prefix)
-	if prefix == "" {
+// GetCompletions provides list of builtin symbols whose name fuzzy matches
+// pattern, the best matches first.
+func GetCompletions(pattern string) []protocol.CompletionItem {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
 		return nil
 	}
 
-	key, remainder := getBucketKey(prefix)
-	bucket, ok := buckets[key]
-	if !ok {
-		return nil
-	}
-
-	if remainder == 0 {
-		return bucket
-	}
-
-	// most buckets contain only single value
-	if len(bucket) == 1 && strings.HasPrefix(bucket[0].Label, prefix) {
-		return bucket
+	type match struct {
+		item  protocol.CompletionItem
+		score float64
 	}
-
-	var items []protocol.CompletionItem
-	for _, item := range bucket {
-		// TODO: use fuzzy find?
-		if strings.HasPrefix(item.Label, prefix) {
-			items = append(items, item)
+	var matches []match
+	matcher := fuzzy.NewMatcher(pattern)
+	for _, bucket := range buckets {
+		for _, item := range bucket {
+			if score := matcher.Score(item.Label); score > 0 {
+				matches = append(matches, match{item: item, score: score})
+			}
 		}
 	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].item.Label < matches[j].item.Label
+	})
 
+	items := make([]protocol.CompletionItem, 0, len(matches))
+	for _, m := range matches {
+		items = append(items, m.item)
+	}
 	return items
 }
-
-func getBucketKey(str string) (rune, int) {
-	runes := []rune(str)
-	return runes[0], len(runes) - 1
-}
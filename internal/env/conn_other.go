@@ -10,6 +10,10 @@ import (
 	"go.lsp.dev/pkg/fakenet"
 )
 
+// Stdout is the standard output the connection writes to, it stays the
+// process stdout when os.Stdout is redirected.
+var Stdout = os.Stdout
+
 func GetConnection(_ context.Context) (net.Conn, error) {
-	return fakenet.NewConn("stdio", os.Stdin, os.Stdout), nil
+	return fakenet.NewConn("stdio", os.Stdin, Stdout), nil
 }
@@ -0,0 +1,157 @@
+// Package fuzzy implements fuzzy matching of identifiers, used to filter
+// and rank symbols and completion items.
+package fuzzy
+
+import (
+	"math"
+	"unicode"
+)
+
+// Scoring weights.
+const (
+	matchScore       = 1.0
+	startBonus       = 2.0  // match on the first rune of the candidate
+	boundaryBonus    = 1.5  // match on a word boundary (camel-case hump, after `_`, `.`...)
+	consecutiveBonus = 1.0  // match following the previous one
+	caseBonus        = 0.25 // match with the same case
+	gapPenalty       = 0.2  // per rune skipped between two matches
+
+	maxRuneScore = matchScore + boundaryBonus + consecutiveBonus + caseBonus
+)
+
+// Matcher scores candidates against a pattern. It can be reused for many
+// candidates but is not safe for concurrent use.
+type Matcher struct {
+	pattern      []rune
+	lowerPattern []rune
+
+	// DP rows, reused between candidates.
+	prev, cur []float64
+}
+
+// NewMatcher returns a Matcher for pattern.
+func NewMatcher(pattern string) *Matcher {
+	m := &Matcher{pattern: []rune(pattern)}
+	m.lowerPattern = make([]rune, len(m.pattern))
+	for i, r := range m.pattern {
+		m.lowerPattern[i] = unicode.ToLower(r)
+	}
+	return m
+}
+
+// Score returns a score in [0, 1] measuring how well the pattern matches
+// candidate, 0 meaning no match. Pattern runes have to appear in the same
+// order in candidate, case insensitively. Matches on the start of the
+// candidate and on word boundaries (e.g. `T` and `N` in `avl.NewTree`) are
+// favored, as well as consecutive matches and shorter candidates, so that
+// `nt` ranks `NewTree` before `Print`.
+func (m *Matcher) Score(candidate string) float64 {
+	if len(m.pattern) == 0 {
+		return 1
+	}
+	cand := []rune(candidate)
+	if len(cand) < len(m.pattern) || !m.isSubsequence(cand) {
+		return 0
+	}
+
+	none := math.Inf(-1)
+	if cap(m.prev) < len(cand) {
+		m.prev = make([]float64, len(cand))
+		m.cur = make([]float64, len(cand))
+	}
+	prev, cur := m.prev[:len(cand)], m.cur[:len(cand)]
+
+	// prev[j] (resp. cur[j]) is the best score of matching the pattern up
+	// to the previous (resp. current) rune, with that rune matched on
+	// cand[j].
+	for i := range m.pattern {
+		best := none // best prev[k] + k*gapPenalty for k < j-1
+		for j := range cand {
+			cur[j] = none
+			if j >= 2 && prev[j-2] != none && i > 0 {
+				if v := prev[j-2] + float64(j-2)*gapPenalty; best == none || v > best {
+					best = v
+				}
+			}
+			if unicode.ToLower(cand[j]) != m.lowerPattern[i] {
+				continue
+			}
+			bonus := m.runeBonus(cand, i, j)
+			if i == 0 {
+				// Small penalty for skipped leading runes.
+				cur[j] = bonus - float64(j)*gapPenalty/2
+				continue
+			}
+			if best != none {
+				cur[j] = best - float64(j-1)*gapPenalty + bonus
+			}
+			if j > 0 && prev[j-1] != none {
+				if v := prev[j-1] + bonus + consecutiveBonus; v > cur[j] {
+					cur[j] = v
+				}
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	score := none
+	for _, v := range prev {
+		if v > score {
+			score = v
+		}
+	}
+	if score <= 0 {
+		// Matched, but very poorly.
+		return 0.001
+	}
+	score /= float64(len(m.pattern)) * maxRuneScore
+	// Favor shorter candidates, an exact match scoring the most.
+	score *= 0.7 + 0.3*float64(len(m.pattern))/float64(len(cand))
+	if score > 1 {
+		score = 1
+	}
+	return score
+}
+
+func (m *Matcher) runeBonus(cand []rune, i, j int) float64 {
+	bonus := matchScore
+	switch {
+	case j == 0:
+		bonus += startBonus
+	case isBoundary(cand, j):
+		bonus += boundaryBonus
+	}
+	if cand[j] == m.pattern[i] {
+		bonus += caseBonus
+	}
+	return bonus
+}
+
+func (m *Matcher) isSubsequence(cand []rune) bool {
+	i := 0
+	for _, r := range cand {
+		if i < len(m.lowerPattern) && unicode.ToLower(r) == m.lowerPattern[i] {
+			i++
+		}
+	}
+	return i == len(m.lowerPattern)
+}
+
+// isBoundary returns true if cand[j] starts a new word.
+func isBoundary(cand []rune, j int) bool {
+	prev, cur := cand[j-1], cand[j]
+	switch {
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev):
+		return true // after `_`, `.`, `/`...
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return true // camel-case hump
+	case unicode.IsDigit(prev) != unicode.IsDigit(cur):
+		return true
+	}
+	return false
+}
+
+// Score is a shortcut for NewMatcher(pattern).Score(candidate).
+func Score(pattern, candidate string) float64 {
+	return NewMatcher(pattern).Score(candidate)
+}
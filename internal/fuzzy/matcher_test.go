@@ -0,0 +1,72 @@
+package fuzzy
+
+import "testing"
+
+func TestScoreRanking(t *testing.T) {
+	tests := []struct {
+		name          string
+		pattern       string
+		better, worse string
+	}{
+		{"word starts", "nt", "NewTree", "Print"},
+		{"exact match", "tree", "tree", "treeNode"},
+		{"case bonus", "Get", "Get", "get"},
+		{"boundary over inner runes", "gs", "GetSize", "gas"},
+		{"gap penalty", "ab", "axbyyyyy", "ayyyyyxb"},
+		{"shorter candidate", "tree", "Tree", "TreeNode"},
+		{"start over boundary", "tree", "Tree", "avl.Tree"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			better, worse := Score(tt.pattern, tt.better), Score(tt.pattern, tt.worse)
+			if better <= worse {
+				t.Errorf("Score(%q, %q) = %v, want more than Score(%q, %q) = %v",
+					tt.pattern, tt.better, better, tt.pattern, tt.worse, worse)
+			}
+		})
+	}
+}
+
+func TestScoreNoMatch(t *testing.T) {
+	tests := []struct {
+		pattern, candidate string
+	}{
+		{"xyz", "abc"},
+		{"tn", "NewTree"}, // out of order
+		{"treenode", "tree"},
+		{"a", ""},
+	}
+	for _, tt := range tests {
+		if got := Score(tt.pattern, tt.candidate); got != 0 {
+			t.Errorf("Score(%q, %q) = %v, want 0", tt.pattern, tt.candidate, got)
+		}
+	}
+}
+
+func TestScoreBounds(t *testing.T) {
+	tests := []struct {
+		pattern, candidate string
+	}{
+		{"", "anything"},
+		{"nt", "nt"},
+		{"avl.Tree", "avl.Tree"},
+		{"Get", "GetSize"},
+		{"z", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaz"},
+	}
+	for _, tt := range tests {
+		if got := Score(tt.pattern, tt.candidate); got <= 0 || got > 1 {
+			t.Errorf("Score(%q, %q) = %v, want in (0, 1]", tt.pattern, tt.candidate, got)
+		}
+	}
+}
+
+func TestMatcherReuse(t *testing.T) {
+	m := NewMatcher("nt")
+	long := m.Score("SomeVeryLongCandidateNameTree")
+	if got, want := m.Score("NewTree"), Score("nt", "NewTree"); got != want {
+		t.Errorf("reused Score(%q) = %v, want %v", "NewTree", got, want)
+	}
+	if got := m.Score("SomeVeryLongCandidateNameTree"); got != long {
+		t.Errorf("reused Score(%q) = %v, want %v", "SomeVeryLongCandidateNameTree", got, long)
+	}
+}
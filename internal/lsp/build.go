@@ -1,142 +1,71 @@
 package lsp
 
 import (
-	"fmt"
-	"log/slog"
-	"path/filepath"
-	"regexp"
-	"strconv"
+	"go/scanner"
+	"go/token"
 	"strings"
 
-	"github.com/gnolang/gnopls/internal/tools"
+	"go.lsp.dev/protocol"
 )
 
 type ErrorInfo struct {
 	FileName string
-	Line     int
-	Column   int
-	Span     []int
+	Range    protocol.Range
 	Msg      string
 	Tool     string
+	// Severity defaults to protocol.DiagnosticSeverityError.
+	Severity protocol.DiagnosticSeverity
+	Related  []protocol.DiagnosticRelatedInformation
 }
 
-func (s *server) TranspileAndBuild(file *GnoFile) ([]ErrorInfo, error) {
-	pkgDir := filepath.Dir(file.URI.Filename())
-	pkgName := filepath.Base(pkgDir)
-	tmpDir := filepath.Join(s.env.GNOHOME, "gnopls", "tmp", pkgName)
-
-	err := copyDir(pkgDir, tmpDir)
-	if err != nil {
-		return nil, err
+// parseErrors converts the errors returned by the go parser when parsing
+// src. Their ranges span the offending token.
+func parseErrors(err error, src []byte) []ErrorInfo {
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		return nil
 	}
-
-	preOut, _ := tools.Transpile(tmpDir)
-	slog.Info(string(preOut))
-	if len(preOut) > 0 {
-		return parseErrors(file, string(preOut), "transpile")
+	errors := make([]ErrorInfo, 0, len(list))
+	overlay := map[string][]byte{}
+	for _, e := range list {
+		overlay[e.Pos.Filename] = src
+		end := e.Pos
+		n := tokenLen(src, e.Pos.Offset)
+		end.Offset += n
+		end.Column += n
+		errors = append(errors, ErrorInfo{
+			FileName: e.Pos.Filename,
+			Range:    protocol.Range{Start: lspPosition(e.Pos, overlay), End: lspPosition(end, overlay)},
+			Msg:      e.Msg,
+			Tool:     "parse",
+		})
 	}
-
-	buildOut, _ := tools.Build(tmpDir)
-	slog.Info(string(buildOut))
-	return parseErrors(file, string(buildOut), "build")
+	return errors
 }
 
-// This is used to extract information from the `gno build` command
-// (see `parseError` below).
-//
-// TODO: Maybe there's a way to get this in a structured format?
-var errorRe = regexp.MustCompile(`(?m)^([^#]+?):(\d+):(\d+):(.+)$`)
-
-// parseErrors parses the output of the `gno build` command for errors.
-//
-// They look something like this:
-//
-// ```
-// command-line-arguments
-// # command-line-arguments
-// <file>:20:9: undefined: strin
-//
-// <pkg_path>: build pkg: std go compiler: exit status 1
-//
-// 1 go build errors
-// ```
-func parseErrors(file *GnoFile, output, cmd string) ([]ErrorInfo, error) {
-	errors := []ErrorInfo{}
-
-	matches := errorRe.FindAllStringSubmatch(output, -1)
-	if len(matches) == 0 {
-		return errors, nil
+// tokenLen returns the length in bytes of the token starting at offset in
+// src, up to the end of its line. It's 0 if no token starts at offset, e.g.
+// at the end of a line or of src.
+func tokenLen(src []byte, offset int) int {
+	if offset < 0 || offset >= len(src) {
+		return 0
 	}
-
-	for _, match := range matches {
-		line, err := strconv.Atoi(match[2])
-		if err != nil {
-			return nil, err
-		}
-
-		column, err := strconv.Atoi(match[3])
-		if err != nil {
-			return nil, err
-		}
-		slog.Info("parsing", "line", line, "column", column, "msg", match[4])
-
-		errorInfo := findError(file, match[1], line, column, match[4], cmd)
-		errors = append(errors, errorInfo)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src)-offset)
+	var s scanner.Scanner
+	s.Init(file, src[offset:], nil, scanner.ScanComments)
+	pos, tok, lit := s.Scan()
+	if tok == token.EOF || file.Offset(pos) != 0 {
+		return 0
 	}
-
-	return errors, nil
-}
-
-// findError finds the error in the document, shifting the line and column
-// numbers to account for the header information in the generated Go file.
-func findError(file *GnoFile, fname string, line, col int, msg string, tool string) ErrorInfo {
-	msg = strings.TrimSpace(msg)
-	// TODO: can be removed?
-	// see: https://github.com/gnolang/gno/pull/1670
-	if tool == "transpile" {
-		// fname parsed from transpile result can be incorrect
-		// e.g filename = `filename.gno: transpile: parse: tmp.gno`
-		parts := strings.Split(fname, ":")
-		fname = parts[0]
+	if tok == token.SEMICOLON && lit == "\n" {
+		return 0 // implicit semicolon
 	}
-
-	// Error messages are of the form:
-	//
-	// <token> <error> (<info>)
-	// <error>: <token>
-	//
-	// We want to strip the parens and find the token in the file.
-	parens := regexp.MustCompile(`\((.+)\)`)
-	needle := parens.ReplaceAllString(msg, "")
-	tokens := strings.Fields(needle)
-
-	errorInfo := ErrorInfo{
-		FileName: strings.TrimPrefix(GoToGnoFileName(filepath.Base(fname)), "."),
-		Line:     line,
-		Column:   col,
-		Span:     []int{0, 0},
-		Msg:      msg,
-		Tool:     tool,
+	if lit == "" { // operators and delimiters
+		lit = tok.String()
 	}
-
-	lines := strings.SplitAfter(string(file.Src), "\n")
-	for i, l := range lines {
-		if i != line-1 { // zero-indexed
-			continue
-		}
-		for _, token := range tokens {
-			tokRe := regexp.MustCompile(fmt.Sprintf(`\b%s\b`, regexp.QuoteMeta(token)))
-			if tokRe.MatchString(l) {
-				errorInfo.Line = i + 1
-				errorInfo.Span = []int{col, col + len(token)}
-				return errorInfo
-			}
-		}
+	if i := strings.IndexByte(lit, '\n'); i >= 0 {
+		lit = lit[:i]
 	}
-
-	// If we couldn't find the token, just return the original error + the
-	// full line.
-	errorInfo.Span = []int{col, col + 1}
-
-	return errorInfo
+	return len(lit)
 }
@@ -1,11 +1,5 @@
 package lsp
 
-import (
-	"go/ast"
-	"go/types"
-	"strings"
-)
-
 // Builtin types
 const (
 	boolDoc    = "bool is the set of boolean values, true and false."
@@ -44,86 +38,43 @@ const (
 	recoverDoc = "The recover built-in function allows a program to manage behavior of a panicking goroutine. Executing a call to recover inside a deferred function (but not any function called by it) stops the panicking sequence by restoring normal execution and retrieves the error value passed to the call of panic. If recover is called outside the deferred function it will not stop a panicking sequence."
 )
 
-func isBuiltin(i *ast.Ident, tv *types.TypeAndValue) (string, bool) {
-	t := tv.Type.String()
-	name := i.Name
-	if strings.Contains(t, "gno.land/") {
-		return "", false
-	}
-
-	if name == "nil" && t == "untyped nil" { // special case?
-		return nilDoc, true
-	}
-	if (name == "true" || name == "false") && t == "bool" { // special case?
-		return boolDoc, true
-	}
-	if name == t { // hover on the type itself?
-		switch t {
-		case "byte":
-			return byteDoc, true
-		case "error":
-			return errorDoc, true
-		case "int":
-			return intDoc, true
-		case "int8":
-			return int8Doc, true
-		case "int16":
-			return int16Doc, true
-		case "int32":
-			return int32Doc, true
-		case "int64":
-			return int64Doc, true
-		case "uint":
-			return uintDoc, true
-		case "uint8":
-			return uint8Doc, true
-		case "uint16":
-			return uint16Doc, true
-		case "uint32":
-			return uint32Doc, true
-		case "uint64":
-			return uint64Doc, true
-		case "float32":
-			return float32Doc, true
-		case "float64":
-			return float64Doc, true
-		case "rune":
-			return runeDoc, true
-		case "string":
-			return stringDoc, true
-		case "nil":
-			return nilDoc, true
-		}
-	}
-
-	if strings.HasPrefix(t, "func") {
-		switch name {
-		case "append":
-			return appendDoc, true
-		case "cap":
-			return capDoc, true
-		case "clear":
-			return clearDoc, true
-		case "copy":
-			return copyDoc, true
-		case "delete":
-			return deleteDoc, true
-		case "len":
-			return lenDoc, true
-		case "make":
-			return makeDoc, true
-		case "new":
-			return newDoc, true
-		case "panic":
-			return panicDoc, true
-		case "print":
-			return printDoc, true
-		case "println":
-			return printlnDoc, true
-		case "recover":
-			return recoverDoc, true
-		}
-	}
+// builtinDocs are the docs of the builtins, by name.
+var builtinDocs = map[string]string{
+	"bool":    boolDoc,
+	"true":    boolDoc,
+	"false":   boolDoc,
+	"byte":    byteDoc,
+	"error":   errorDoc,
+	"int":     intDoc,
+	"int8":    int8Doc,
+	"int16":   int16Doc,
+	"int32":   int32Doc,
+	"int64":   int64Doc,
+	"uint":    uintDoc,
+	"uint8":   uint8Doc,
+	"uint16":  uint16Doc,
+	"uint32":  uint32Doc,
+	"uint64":  uint64Doc,
+	"float32": float32Doc,
+	"float64": float64Doc,
+	"rune":    runeDoc,
+	"string":  stringDoc,
+	"nil":     nilDoc,
+	"append":  appendDoc,
+	"cap":     capDoc,
+	"clear":   clearDoc,
+	"copy":    copyDoc,
+	"delete":  deleteDoc,
+	"len":     lenDoc,
+	"make":    makeDoc,
+	"new":     newDoc,
+	"panic":   panicDoc,
+	"print":   printDoc,
+	"println": printlnDoc,
+	"recover": recoverDoc,
+}
 
-	return "", false
+// builtinDoc returns the doc of the builtin named name, or "".
+func builtinDoc(name string) string {
+	return builtinDocs[name]
 }
@@ -1,6 +1,8 @@
 package lsp
 
 import (
+	"context"
+
 	cmap "github.com/orcaman/concurrent-map/v2"
 )
 
@@ -28,25 +30,65 @@ func NewCache() *Cache {
 	}
 }
 
-func (s *server) UpdateCache(pkgPath string) {
+// UpdateCache type-checks the package located in pkgPath, using the files
+// open in snapshot, and stores the result in the cache.
+func (s *server) UpdateCache(ctx context.Context, snapshot *Snapshot, pkgPath string) *Package {
 	// TODO: Unify `GetPackageInfo()` and `PackageFromDir()`?
-	pkg, err := PackageFromDir(pkgPath, false)
+	overlay := snapshot.Overlay()
+	pkg, err := packageFromDir(pkgPath, false, overlay)
 	if err != nil {
-		return
+		return nil
 	}
-	pkginfo, err := GetPackageInfo(pkgPath)
+	pkginfo, err := getPackageInfo(pkgPath, overlay)
 	if err != nil {
-		return
+		return nil
 	}
 
-	tc, errs := NewTypeCheck()
-	tc.cfg.Importer = tc // set typeCheck importer
-	res := pkginfo.TypeCheck(tc)
+	tc, errs := s.newTypeCheck(snapshot)
+	res := pkginfo.TypeCheck(ctx, tc)
+	if ctx.Err() != nil {
+		// Incomplete result
+		return nil
+	}
 
 	// Mutate `res.err` with `errs`, as `res.err` contains
 	// only the first error found.
 	res.err = *errs
 
 	pkg.TypeCheckResult = res // set typeCheck result
-	s.cache.pkgs.Set(pkgPath, pkg)
+	pkg.snapshotID = snapshot.ID()
+	// Don't overwrite the result of a newer snapshot, stored meanwhile.
+	s.cache.pkgs.Upsert(pkgPath, pkg, func(exist bool, old, new *Package) *Package {
+		if exist && old.snapshotID > new.snapshotID {
+			return old
+		}
+		return new
+	})
+	return pkg
+}
+
+// getPackage returns the package located in dir as seen by snapshot. The
+// cached package is returned if it is up to date, otherwise the package is
+// type-checked again. If that fails, for instance because a file is being
+// edited and doesn't parse, the previous result is returned.
+func (s *server) getPackage(ctx context.Context, snapshot *Snapshot, dir string) (*Package, bool) {
+	cached, ok := s.cache.pkgs.Get(dir)
+	if ok && cached.snapshotID == snapshot.ID() {
+		return cached, true
+	}
+	if pkg := s.UpdateCache(ctx, snapshot, dir); pkg != nil {
+		return pkg, true
+	}
+	return cached, ok
+}
+
+// lookupPackage returns the package of the given import path, from the
+// workspace or from GNOROOT.
+func (s *server) lookupPackage(ctx context.Context, snapshot *Snapshot, path string) *Package {
+	if dir, ok := s.workspacePackageDirs()[path]; ok {
+		if pkg, ok := s.getPackage(ctx, snapshot, dir); ok {
+			return pkg
+		}
+	}
+	return s.completionStore.lookupPkg(path)
 }
@@ -0,0 +1,284 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+
+	"github.com/gnolang/gno/gnovm/pkg/gnolang"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+func (s *server) PrepareCallHierarchy(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.CallHierarchyPrepareParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	snapshot := s.getSnapshot()
+	tcr, fn := s.funcAt(ctx, snapshot, params.TextDocument.URI, params.Position)
+	if fn == nil {
+		return reply(ctx, nil, nil)
+	}
+	item, ok := callHierarchyItem(tcr, fn)
+	if !ok {
+		return reply(ctx, nil, nil)
+	}
+	return reply(ctx, []protocol.CallHierarchyItem{item}, nil)
+}
+
+func (s *server) IncomingCalls(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.CallHierarchyIncomingCallsParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	calls, err := s.incomingCalls(ctx, s.getSnapshot(), params.Item)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, calls, nil)
+}
+
+func (s *server) OutgoingCalls(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.CallHierarchyOutgoingCallsParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	calls, err := s.outgoingCalls(ctx, s.getSnapshot(), params.Item)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, calls, nil)
+}
+
+// incomingCalls returns the functions calling the function of item, in its
+// package and, if exported, in the workspace packages importing it.
+func (s *server) incomingCalls(ctx context.Context, snapshot *Snapshot, item protocol.CallHierarchyItem) ([]protocol.CallHierarchyIncomingCall, error) {
+	tcr, fn := s.funcAt(ctx, snapshot, item.URI, item.SelectionRange.Start)
+	if fn == nil {
+		return nil, errors.New("function not found")
+	}
+	key := objectKey(tcr.fset, fn)
+
+	dir := filepath.Dir(item.URI.Filename())
+	dirs := []string{dir}
+	if fn.Exported() {
+		overlay := snapshot.Overlay()
+		for path, wsDir := range s.workspacePackageDirs() {
+			if wsDir == dir || path == fn.Pkg().Path() || !importsPath(overlay, wsDir, fn.Pkg().Path()) {
+				continue
+			}
+			dirs = append(dirs, wsDir)
+		}
+	}
+
+	// The package of item is checked along with its test files, the
+	// importers are read from the cache.
+	tc, _ := s.newTypeCheck(snapshot)
+	units, err := checkPackageDir(ctx, tc, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs[1:] {
+		pkg, ok := s.getPackage(ctx, snapshot, dir)
+		if ok && pkg.TypeCheckResult != nil {
+			units = append(units, pkg.TypeCheckResult)
+		}
+	}
+
+	calls := []protocol.CallHierarchyIncomingCall{}
+	for _, unit := range units {
+		for _, decl := range funcDecls(unit) {
+			caller, ok := unit.info.Defs[decl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			var ranges []protocol.Range
+			for _, call := range calledFuncs(unit.info, decl) {
+				if objectKey(unit.fset, call.fn) == key {
+					ranges = append(ranges, call.ranges(unit.fset, unit.tc.overlay)...)
+				}
+			}
+			if len(ranges) == 0 {
+				continue
+			}
+			if from, ok := callHierarchyItem(unit, caller); ok {
+				calls = append(calls, protocol.CallHierarchyIncomingCall{
+					From:       from,
+					FromRanges: ranges,
+				})
+			}
+		}
+	}
+	return calls, nil
+}
+
+// outgoingCalls returns the functions called by the function of item.
+func (s *server) outgoingCalls(ctx context.Context, snapshot *Snapshot, item protocol.CallHierarchyItem) ([]protocol.CallHierarchyOutgoingCall, error) {
+	tcr, fn := s.funcAt(ctx, snapshot, item.URI, item.SelectionRange.Start)
+	if fn == nil {
+		return nil, errors.New("function not found")
+	}
+	decl := funcDeclOf(tcr, fn)
+	if decl == nil {
+		return nil, nil
+	}
+
+	calls := []protocol.CallHierarchyOutgoingCall{}
+	for _, call := range calledFuncs(tcr.info, decl) {
+		to, ok := callHierarchyItem(tcr, call.fn)
+		if !ok {
+			continue // methods of builtin types, like error.Error
+		}
+		calls = append(calls, protocol.CallHierarchyOutgoingCall{
+			To:         to,
+			FromRanges: call.ranges(tcr.fset, tcr.tc.overlay),
+		})
+	}
+	return calls, nil
+}
+
+// funcAt returns the function or method declared or referred to at pos in
+// the file of the given uri, and the type-check result of the file, which
+// can be a test file. The file may not be open, like the files of GNOROOT
+// returned by the previous calls. The functions without position, like the
+// methods of the builtin types, are left out.
+func (s *server) funcAt(ctx context.Context, snapshot *Snapshot, uri protocol.DocumentURI, pos protocol.Position) (*TypeCheckResult, *types.Func) {
+	filename := uri.Filename()
+	file, ok := snapshot.Get(filename)
+	if !ok {
+		src, err := readFile(filename, nil)
+		if err != nil {
+			return nil, nil
+		}
+		file = &GnoFile{URI: uri, Src: src}
+	}
+	tc, tcr, _, err := s.checkFile(ctx, snapshot, file)
+	if err != nil {
+		return nil, nil
+	}
+	offset, err := positionToOffset(file.Src, pos)
+	if err != nil {
+		return nil, nil
+	}
+	obj, err := objectAt(tc.fset, []*TypeCheckResult{tcr}, filename, offset)
+	if err != nil {
+		return nil, nil
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok || !fn.Pos().IsValid() {
+		return nil, nil
+	}
+	return tcr, fn
+}
+
+// callHierarchyItem returns the item of fn. Its range covers the
+// declaration of fn if it's part of tcr, otherwise only its name. Exported
+// functions of realms are flagged as entry points, as anyone can call them.
+// false is returned if fn has no location, like the methods of the builtin
+// types.
+func callHierarchyItem(tcr *TypeCheckResult, fn *types.Func) (protocol.CallHierarchyItem, bool) {
+	loc := objectLocation(tcr.fset, fn, tcr.tc.overlay)
+	if loc == nil {
+		return protocol.CallHierarchyItem{}, false
+	}
+	item := protocol.CallHierarchyItem{
+		Name:           fn.Name(),
+		Kind:           protocol.SymbolKindFunction,
+		URI:            loc.URI,
+		Range:          loc.Range,
+		SelectionRange: loc.Range,
+	}
+	if fn.Pkg() != nil {
+		item.Detail = fn.Pkg().Path()
+	}
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		qf := func(*types.Package) string { return "" }
+		item.Name = "(" + types.TypeString(recv.Type(), qf) + ")." + fn.Name()
+		item.Kind = protocol.SymbolKindMethod
+	} else if fn.Exported() && gnolang.IsRealmPath(item.Detail) {
+		item.Detail += " • entry point"
+	}
+	if decl := funcDeclOf(tcr, fn); decl != nil {
+		item.Range = nodeToRange(tcr.fset, decl, tcr.tc.overlay)
+	}
+	return item, true
+}
+
+// funcDecls returns the declarations of the functions and methods of tcr.
+func funcDecls(tcr *TypeCheckResult) []*ast.FuncDecl {
+	var decls []*ast.FuncDecl
+	for _, f := range tcr.files {
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok {
+				decls = append(decls, fd)
+			}
+		}
+	}
+	return decls
+}
+
+// funcDeclOf returns the declaration of fn, or nil if it's not part of tcr.
+func funcDeclOf(tcr *TypeCheckResult, fn *types.Func) *ast.FuncDecl {
+	for _, decl := range funcDecls(tcr) {
+		if decl.Name.Pos() == fn.Pos() {
+			return decl
+		}
+	}
+	return nil
+}
+
+// funcCall is a function or method referred to by the body of another.
+type funcCall struct {
+	fn  *types.Func
+	ids []*ast.Ident
+}
+
+// ranges returns the ranges of the references of c, whose files are read
+// from overlay if open.
+func (c *funcCall) ranges(fset *token.FileSet, overlay map[string][]byte) []protocol.Range {
+	ranges := make([]protocol.Range, 0, len(c.ids))
+	for _, id := range c.ids {
+		ranges = append(ranges, nodeToRange(fset, id, overlay))
+	}
+	return ranges
+}
+
+// calledFuncs returns the functions and methods referred to by the body of
+// decl, in order of first reference. Functions referred to without being
+// called, like method values or functions passed as arguments, are
+// included as they can be called later, as well as the calls made by the
+// function literals of decl.
+func calledFuncs(info *types.Info, decl *ast.FuncDecl) []*funcCall {
+	if decl.Body == nil {
+		return nil
+	}
+	var calls []*funcCall
+	byFunc := map[*types.Func]*funcCall{}
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		fn, ok := info.Uses[id].(*types.Func)
+		if !ok {
+			return true
+		}
+		call, ok := byFunc[fn]
+		if !ok {
+			call = &funcCall{fn: fn}
+			byFunc[fn] = call
+			calls = append(calls, call)
+		}
+		call.ids = append(call.ids, id)
+		return true
+	})
+	return calls
+}
@@ -0,0 +1,37 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestIncomingCallsTestFile(t *testing.T) {
+	s, dir := newTestServer(t, map[string]string{
+		"gno.mod":     "module gno.land/p/test/ch\n",
+		"ch.gno":      "package ch\n\nfunc Add(a, b int) int { return a + b }\n",
+		"ch_test.gno": "package ch\n\nfunc testAdd() int {\n\treturn Add(1, 2)\n}\n",
+	})
+	ctx := context.Background()
+	snapshot := s.getSnapshot()
+	// The call hierarchy is prepared from the test file.
+	test := testFile(t, s, dir, "ch_test.gno")
+	tcr, fn := s.funcAt(ctx, snapshot, test.URI, protocol.Position{Line: 3, Character: 9})
+	if fn == nil || fn.Name() != "Add" {
+		t.Fatalf("got function %v, want Add", fn)
+	}
+	item, ok := callHierarchyItem(tcr, fn)
+	if !ok {
+		t.Fatal("no call hierarchy item")
+	}
+
+	calls, err := s.incomingCalls(ctx, snapshot, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := protocol.Range{Start: protocol.Position{Line: 3, Character: 8}, End: protocol.Position{Line: 3, Character: 11}}
+	if len(calls) != 1 || calls[0].From.Name != "testAdd" || calls[0].From.URI != test.URI || len(calls[0].FromRanges) != 1 || calls[0].FromRanges[0] != want {
+		t.Errorf("got %+v, want a call from testAdd at %v", calls, want)
+	}
+}
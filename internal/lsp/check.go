@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"go/ast"
@@ -8,16 +9,18 @@ import (
 	"go/token"
 	"go/types"
 	"log/slog"
-	"math"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/gnolang/gno/gnovm/pkg/gnomod"
 	"github.com/gnolang/gnopls/internal/env"
+	"go.lsp.dev/protocol"
 	"go.uber.org/multierr"
+	"golang.org/x/tools/go/ast/astutil"
 )
 
 type FileInfo struct {
@@ -38,24 +41,51 @@ type PackageGetter interface {
 // PackageInfo if found.
 // Note: it doesn't work for relative path
 func GetPackageInfo(path string) (*PackageInfo, error) {
+	dir, err := packageDir(path)
+	if err != nil {
+		return nil, err
+	}
+	return getPackageInfo(dir, nil)
+}
+
+// packageDir returns the directory of path, which is either absolute or an
+// import path located in GNOROOT, or downloaded in GNOHOME.
+func packageDir(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
 	// if not absolute, assume its import path
-	if !filepath.IsAbs(path) {
-		if env.GlobalEnv.GNOROOT == "" {
-			// if GNOROOT is unknown, we can't locate the
-			// `examples` and `stdlibs`
-			return nil, errors.New("GNOROOT not set")
+	if strings.HasPrefix(path, "gno.land/") {
+		// look in `examples`, then in the downloaded packages
+		var dirs []string
+		if env.GlobalEnv.GNOROOT != "" {
+			dirs = append(dirs, filepath.Join(env.GlobalEnv.GNOROOT, "examples", path))
 		}
-		if strings.HasPrefix(path, "gno.land/") { // look in `examples`
-			path = filepath.Join(env.GlobalEnv.GNOROOT, "examples", path)
-		} else { // look into `stdlibs`
-			path = filepath.Join(env.GlobalEnv.GNOROOT, "gnovm", "stdlibs", path)
+		if env.GlobalEnv.GNOHOME != "" {
+			dirs = append(dirs, filepath.Join(env.GlobalEnv.GNOHOME, "pkg", "mod", path))
 		}
+		if len(dirs) == 0 {
+			return "", errors.New("GNOROOT not set")
+		}
+		for _, dir := range dirs {
+			if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+				return dir, nil
+			}
+		}
+		return dirs[0], nil
+	}
+	if env.GlobalEnv.GNOROOT == "" {
+		// if GNOROOT is unknown, we can't locate the `stdlibs`
+		return "", errors.New("GNOROOT not set")
 	}
-	return getPackageInfo(path)
+	// look into `stdlibs`
+	return filepath.Join(env.GlobalEnv.GNOROOT, "gnovm", "stdlibs", path), nil
 }
 
-func getPackageInfo(path string) (*PackageInfo, error) {
-	filenames, err := ListGnoFiles(path)
+// getPackageInfo reads the package located in path. Files present in
+// overlay are read from it rather than from disk.
+func getPackageInfo(path string, overlay map[string][]byte) (*PackageInfo, error) {
+	filenames, err := listGnoFiles(path, overlay)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +106,7 @@ func getPackageInfo(path string) (*PackageInfo, error) {
 		if err != nil {
 			return nil, err
 		}
-		bsrc, err := os.ReadFile(absPath)
+		bsrc, err := readFile(absPath, overlay)
 		if err != nil {
 			return nil, err
 		}
@@ -90,15 +120,59 @@ func getPackageInfo(path string) (*PackageInfo, error) {
 	}, nil
 }
 
+// readFile returns the content of the named file, from overlay if present,
+// otherwise from disk.
+func readFile(name string, overlay map[string][]byte) ([]byte, error) {
+	if src, ok := overlay[name]; ok {
+		return src, nil
+	}
+	return os.ReadFile(name)
+}
+
+// listGnoFiles returns the gno files of dir, including those only present
+// in overlay (e.g. new files not saved yet).
+func listGnoFiles(dir string, overlay map[string][]byte) ([]string, error) {
+	files, err := ListGnoFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	added := false
+	for name := range overlay {
+		if filepath.Dir(name) != dir || !strings.HasSuffix(name, ".gno") || slices.Contains(files, name) {
+			continue
+		}
+		files = append(files, name)
+		added = true
+	}
+	if added {
+		sort.Strings(files)
+	}
+	return files, nil
+}
+
 type TypeCheck struct {
 	cache map[string]*TypeCheckResult
 	cfg   *types.Config
+	// fset is shared by all the packages checked by tc, so positions of
+	// imported objects can be resolved.
+	fset *token.FileSet
+	// dirs maps import paths to package directories, it is looked up
+	// before GNOROOT when resolving imports (e.g. workspace packages).
+	dirs map[string]string
+	// overlay maps file names to their unsaved content, which is used
+	// instead of the content on disk (see Snapshot.Overlay).
+	overlay map[string][]byte
+	// files holds all the files parsed by tc.
+	files []*ast.File
 }
 
 func NewTypeCheck() (*TypeCheck, *error) {
 	var errs error
 	return &TypeCheck{
-		cache: map[string]*TypeCheckResult{},
+		cache:   map[string]*TypeCheckResult{},
+		fset:    token.NewFileSet(),
+		dirs:    map[string]string{},
+		overlay: map[string][]byte{},
 		cfg: &types.Config{
 			Error: func(err error) {
 				errs = multierr.Append(errs, err)
@@ -107,28 +181,92 @@ func NewTypeCheck() (*TypeCheck, *error) {
 	}, &errs
 }
 
-func (tc *TypeCheck) Import(path string) (*types.Package, error) {
-	return tc.ImportFrom(path, "", 0)
+// fork returns a TypeCheck importing the packages checked by tc, whose
+// files are resolved by the same file set. The packages it checks aren't
+// added to tc.
+func (tc *TypeCheck) fork() *TypeCheck {
+	return &TypeCheck{
+		cache:   maps.Clone(tc.cache),
+		cfg:     tc.cfg,
+		fset:    tc.fset,
+		dirs:    maps.Clone(tc.dirs),
+		overlay: tc.overlay,
+	}
 }
 
-// ImportFrom returns the imported package for the given import path
-func (tc *TypeCheck) ImportFrom(path, _ string, _ types.ImportMode) (*types.Package, error) {
+// Import returns the package of the given import path, type-checking it
+// and its imports unless ctx is cancelled.
+func (tc *TypeCheck) Import(ctx context.Context, path string) (*types.Package, error) {
 	if pkg, ok := tc.cache[path]; ok {
-		return pkg.pkg, pkg.err
+		if pkg.pkg == nil {
+			return nil, pkg.err
+		}
+		return pkg.pkg, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	pkg, err := GetPackageInfo(path)
+	pkg, err := tc.getPackageInfo(path)
 	if err != nil {
 		err := fmt.Errorf("package %q not found", path)
 		tc.cache[path] = &TypeCheckResult{err: err}
 		return nil, err
 	}
-	res := pkg.TypeCheck(tc)
+	if pkg.ImportPath == "" {
+		// Packages without gno.mod, like the stdlibs.
+		pkg.ImportPath = path
+	}
+	res := pkg.TypeCheck(ctx, tc)
+	if ctx.Err() != nil {
+		// Incomplete result, which must not be reused.
+		return res.pkg, nil
+	}
 	tc.cache[path] = res
-	return res.pkg, res.err
+	return res.pkg, nil // errors of imported packages are not reported
+}
+
+// importer is the types.ImporterFrom of the packages checked by tc, which
+// stops importing packages once ctx is cancelled.
+type importer struct {
+	ctx context.Context
+	tc  *TypeCheck
+}
+
+func (imp importer) Import(path string) (*types.Package, error) {
+	return imp.tc.Import(imp.ctx, path)
+}
+
+func (imp importer) ImportFrom(path, _ string, _ types.ImportMode) (*types.Package, error) {
+	return imp.tc.Import(imp.ctx, path)
+}
+
+// getPackageInfo returns the PackageInfo of the given import path, looking
+// into tc.dirs first.
+func (tc *TypeCheck) getPackageInfo(path string) (*PackageInfo, error) {
+	if dir, ok := tc.dirs[path]; ok {
+		return getPackageInfo(dir, tc.overlay)
+	}
+	dir, err := packageDir(path)
+	if err != nil {
+		return nil, err
+	}
+	return getPackageInfo(dir, tc.overlay)
+}
+
+// fileAt returns the parsed file which contains pos, or nil.
+func (tc *TypeCheck) fileAt(pos token.Pos) *ast.File {
+	for _, f := range tc.files {
+		if f.FileStart <= pos && pos <= f.FileEnd {
+			return f
+		}
+	}
+	return nil
 }
 
-func (pi *PackageInfo) TypeCheck(tc *TypeCheck) *TypeCheckResult {
-	fset := token.NewFileSet()
+// TypeCheck type-checks pi with tc. Its imports aren't type-checked anymore
+// once ctx is cancelled, so the result is incomplete.
+func (pi *PackageInfo) TypeCheck(ctx context.Context, tc *TypeCheck) *TypeCheckResult {
+	fset := tc.fset
 	info := &types.Info{
 		Types:      make(map[ast.Expr]types.TypeAndValue),
 		Defs:       make(map[*ast.Ident]types.Object),
@@ -139,24 +277,29 @@ func (pi *PackageInfo) TypeCheck(tc *TypeCheck) *TypeCheckResult {
 		Scopes:     make(map[ast.Node]*types.Scope),
 	}
 	files := make([]*ast.File, 0, len(pi.Files))
-	var errs error
+	var syntaxErrs []ErrorInfo
 	for _, f := range pi.Files {
-		if !strings.HasSuffix(f.Name, ".gno") ||
-			strings.HasSuffix(f.Name, "_filetest.gno") ||
-			strings.HasSuffix(f.Name, "_test.gno") {
+		if !strings.HasSuffix(f.Name, ".gno") {
 			continue
 		}
 
-		pgf, err := parser.ParseFile(fset, f.Name, f.Body, parser.ParseComments|parser.DeclarationErrors|parser.SkipObjectResolution)
+		pgf, err := parser.ParseFile(fset, filepath.Join(pi.Dir, f.Name), f.Body, parser.ParseComments|parser.DeclarationErrors|parser.SkipObjectResolution)
 		if err != nil {
-			errs = multierr.Append(errs, err)
-			continue
+			syntaxErrs = append(syntaxErrs, parseErrors(err, []byte(f.Body))...)
+			if pgf == nil {
+				continue
+			}
+			// Keep the partial AST, so files being edited can still be
+			// type-checked.
 		}
 
 		files = append(files, pgf)
 	}
-	pkg, err := tc.cfg.Check(pi.ImportPath, fset, files, info)
-	return &TypeCheckResult{pkg: pkg, fset: fset, files: files, info: info, err: err}
+	tc.files = append(tc.files, files...)
+	cfg := *tc.cfg
+	cfg.Importer = importer{ctx: ctx, tc: tc}
+	pkg, err := cfg.Check(pi.ImportPath, fset, files, info)
+	return &TypeCheckResult{pkg: pkg, fset: fset, files: files, info: info, err: err, syntaxErrs: syntaxErrs, tc: tc}
 }
 
 type TypeCheckResult struct {
@@ -165,32 +308,84 @@ type TypeCheckResult struct {
 	files []*ast.File
 	info  *types.Info
 	err   error
+	// syntaxErrs holds the parse errors of the files.
+	syntaxErrs []ErrorInfo
+	// tc is the type-check which produced the result, it holds the files of
+	// the imported packages. Once the result is cached, tc is shared and
+	// must only be read: fresh type-checks are used to check other packages.
+	tc *TypeCheck
 }
 
+// Errors returns the syntax errors and the type-check errors of tcr.
 func (tcr *TypeCheckResult) Errors() []ErrorInfo {
-	errs := multierr.Errors(tcr.err)
-	res := make([]ErrorInfo, 0, len(errs))
-	for _, err := range errs {
-		parts := strings.Split(err.Error(), ":")
-		if len(parts) < 4 {
+	return append(slices.Clone(tcr.syntaxErrs), typeErrors(tcr.err, tcr.files, tcr.tc.overlay)...)
+}
+
+// typeErrors converts the errors reported by the type checker, files being
+// the type-checked files, read from overlay if open. Continuation errors, like "other declaration of x"
+// following "x redeclared in this block", are attached as related
+// information to the error they continue.
+func typeErrors(err error, files []*ast.File, overlay map[string][]byte) []ErrorInfo {
+	var res []ErrorInfo
+	// last is the index in res of the error continued by the next
+	// continuation errors, -1 if it was skipped.
+	last := -1
+	for _, err := range multierr.Errors(err) {
+		terr, ok := err.(types.Error)
+		if !ok {
 			slog.Error("TYPECHECK", "skipped", err)
+			last = -1
+			continue
+		}
+		filename := terr.Fset.Position(terr.Pos).Filename
+		rng := errorRange(terr.Fset, files, terr.Pos, overlay)
+
+		if strings.HasPrefix(terr.Msg, "\t") {
+			if last >= 0 {
+				res[last].Related = append(res[last].Related, protocol.DiagnosticRelatedInformation{
+					Location: protocol.Location{URI: getURI(filename), Range: rng},
+					Message:  strings.TrimSpace(terr.Msg),
+				})
+			}
+			continue
+		}
+
+		severity := protocol.DiagnosticSeverityError
+		if terr.Soft { // e.g. unused variables and imports
+			severity = protocol.DiagnosticSeverityWarning
 		}
-		filename := strings.TrimSpace(parts[0])
-		line, _ := strconv.Atoi(strings.TrimSpace(parts[1]))
-		col, _ := strconv.Atoi(strings.TrimSpace(parts[2]))
-		msg := strings.TrimSpace(strings.Join(parts[3:], ":"))
 		res = append(res, ErrorInfo{
 			FileName: filename,
-			Line:     line,
-			Column:   col,
-			Span:     []int{col, math.MaxInt},
-			Msg:      msg,
+			Range:    rng,
+			Msg:      terr.Msg,
 			Tool:     "typecheck",
+			Severity: severity,
 		})
+		last = len(res) - 1
 	}
 	return res
 }
 
+// errorRange returns the range of the expression starting at pos, which is
+// the position of an error. If there's none, the range is empty.
+func errorRange(fset *token.FileSet, files []*ast.File, pos token.Pos, overlay map[string][]byte) protocol.Range {
+	end := pos
+	for _, f := range files {
+		if pos < f.FileStart || pos > f.FileEnd {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+		for _, n := range path {
+			if _, ok := n.(ast.Expr); !ok || n.Pos() != pos {
+				break
+			}
+			end = n.End()
+		}
+		break
+	}
+	return tokenRange(fset, pos, end, overlay)
+}
+
 // Prints types.Info in a tabular form
 // Kept only for debugging purpose.
 func formatTypeInfo(fset *token.FileSet, info *types.Info) string {
@@ -212,67 +407,6 @@ func formatTypeInfo(fset *token.FileSet, info *types.Info) string {
 	return strings.Join(items, "\n")
 }
 
-// Prints types.Info in a tabular form
-// Kept only for debugging purpose.
-func getTypeAndValue(
-	fset *token.FileSet,
-	info *types.Info,
-	tok string,
-	line, offset int,
-) (ast.Expr, *types.TypeAndValue) {
-	for expr, tv := range info.Types {
-		if tok != types.ExprString(expr) {
-			continue
-		}
-		posn := fset.Position(expr.Pos())
-		if line != posn.Line {
-			continue
-		}
-		slog.Info("getTypeInfo", "offset", offset, "pos", expr.Pos(), "end", expr.End())
-		if token.Pos(offset) < expr.Pos() &&
-			token.Pos(offset) > expr.End() {
-			continue
-		}
-
-		// tv.Value.
-		tvstr := tv.Type.String()
-		if tv.Value != nil {
-			tvstr += " = " + tv.Value.String()
-		}
-
-		return expr, &tv
-	}
-	return nil, nil
-}
-
-// Use getTypeAndValue instead
-// TODO: should be removed
-func getTypeAndValueLight(
-	fset *token.FileSet,
-	info *types.Info,
-	tok string,
-	line int,
-) (ast.Expr, *types.TypeAndValue) {
-	for expr, tv := range info.Types {
-		if tok != types.ExprString(expr) {
-			continue
-		}
-		posn := fset.Position(expr.Pos())
-		if line != posn.Line {
-			continue
-		}
-
-		// tv.Value.
-		tvstr := tv.Type.String()
-		if tv.Value != nil {
-			tvstr += " = " + tv.Value.String()
-		}
-
-		return expr, &tv
-	}
-	return nil, nil
-}
-
 func mode(tv types.TypeAndValue) string {
 	switch {
 	case tv.IsVoid():
@@ -0,0 +1,710 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func (s *server) CodeAction(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.CodeActionParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	actions, err := s.codeActions(ctx, snapshot, file, params.Range, params.Context.Only)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, actions, nil)
+}
+
+// codeActions returns the quick fixes of the errors of file overlapping rng,
+// and the organize imports action of file. only filters the kinds of the
+// returned actions, all kinds are returned if it's empty.
+func (s *server) codeActions(ctx context.Context, snapshot *Snapshot, file *GnoFile, rng protocol.Range, only []protocol.CodeActionKind) ([]protocol.CodeAction, error) {
+	filename := file.URI.Filename()
+
+	tc, errs := s.newTypeCheck(snapshot)
+	units, err := checkPackageDir(ctx, tc, filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	unit, f := unitOf(tc.fset, units, filename)
+	if f == nil {
+		return nil, errors.New("file not found in package")
+	}
+	var fileErrs []ErrorInfo
+	for _, er := range typeErrors(*errs, tc.files, tc.overlay) {
+		if er.FileName == filename {
+			fileErrs = append(fileErrs, er)
+		}
+	}
+
+	fx := &fixer{s: s, tc: tc, unit: unit, file: f, src: file.Src}
+	actions := []protocol.CodeAction{}
+	if wantsKind(only, protocol.QuickFix) {
+		for _, er := range fileErrs {
+			if !rangeContains(er.Range, rng.Start) && !rangeContains(rng, er.Range.Start) {
+				continue
+			}
+			for _, action := range fx.quickFixes(ctx, er) {
+				action.Kind = protocol.QuickFix
+				action.Diagnostics = toDiagnostics([]ErrorInfo{er})
+				actions = append(actions, action)
+			}
+		}
+	}
+	if wantsKind(only, protocol.SourceOrganizeImports) {
+		if edits := fx.organizeImports(ctx, fileErrs); len(edits) > 0 {
+			actions = append(actions, protocol.CodeAction{
+				Title: "Organize imports",
+				Kind:  protocol.SourceOrganizeImports,
+				Edit:  &protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{file.URI: edits}},
+			})
+		}
+	}
+	return actions, nil
+}
+
+// wantsKind returns true if the actions of kind are requested by only, which
+// holds kinds or parent kinds (e.g. `source` for `source.organizeImports`).
+func wantsKind(only []protocol.CodeActionKind, kind protocol.CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, k := range only {
+		if k == kind || strings.HasPrefix(string(kind), string(k)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// fixer builds the edits fixing the errors of a type-checked file.
+type fixer struct {
+	s    *server
+	tc   *TypeCheck
+	unit *TypeCheckResult
+	file *ast.File
+	src  []byte // content of file
+}
+
+var missingMethodRe = regexp.MustCompile(`(\*?)(\S+) does not implement (\S+) \(missing method (\w+)\)`)
+
+// quickFixes returns the actions fixing er, an error of fx.file.
+func (fx *fixer) quickFixes(ctx context.Context, er ErrorInfo) []protocol.CodeAction {
+	pos := fx.pos(er.Range.Start)
+	if !pos.IsValid() {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(er.Msg, "undefined: "):
+		return fx.addImportFixes(ctx, pos)
+	case strings.HasPrefix(er.Msg, `"`) && strings.HasSuffix(er.Msg, " and not used"):
+		return fx.removeImportFixes(pos)
+	case strings.HasPrefix(er.Msg, "declared and not used: "),
+		strings.HasSuffix(er.Msg, " declared and not used") && !strings.HasPrefix(er.Msg, "label "):
+		// The latter is reported for type switch variables.
+		return fx.removeVariableFixes(pos)
+	}
+	if m := missingMethodRe.FindStringSubmatch(er.Msg); m != nil {
+		return fx.stubMethodsFixes(ctx, m[1] == "*", m[2], m[3])
+	}
+	return nil
+}
+
+// pos returns the position of p in fx.file, or token.NoPos.
+func (fx *fixer) pos(p protocol.Position) token.Pos {
+	tokFile := fx.tc.fset.File(fx.file.Pos())
+	offset, err := positionToOffset(fx.src, p)
+	if err != nil || offset > tokFile.Size() {
+		return token.NoPos
+	}
+	return tokFile.Pos(offset)
+}
+
+// edit returns a workspace edit holding edits of fx.file.
+func (fx *fixer) edit(edits ...protocol.TextEdit) *protocol.WorkspaceEdit {
+	uri := getURI(fx.tc.fset.File(fx.file.Pos()).Name())
+	return &protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{uri: edits}}
+}
+
+// addImportFixes returns the actions importing the package of the undefined
+// identifier at pos, e.g. `avl` in `avl.Tree`.
+func (fx *fixer) addImportFixes(ctx context.Context, pos token.Pos) []protocol.CodeAction {
+	path, _ := astutil.PathEnclosingInterval(fx.file, pos, pos)
+	if len(path) < 2 {
+		return nil
+	}
+	id, ok := path[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	sel, ok := path[1].(*ast.SelectorExpr)
+	if !ok || sel.X != id {
+		return nil
+	}
+
+	candidates := fx.importCandidates(ctx, id.Name, sel.Sel.Name)
+	var actions []protocol.CodeAction
+	for _, importPath := range candidates {
+		actions = append(actions, protocol.CodeAction{
+			Title:       fmt.Sprintf("Add import %q", importPath),
+			IsPreferred: len(candidates) == 1,
+			Edit:        fx.edit(addImportEdits(fx.tc.fset, fx.file, importPath, fx.tc.overlay)...),
+		})
+	}
+	return actions
+}
+
+// importCandidates returns the import paths of the packages named name which
+// export sel, workspace packages first.
+func (fx *fixer) importCandidates(ctx context.Context, name, sel string) []string {
+	var workspace, others []string
+	for importPath := range fx.tc.dirs {
+		if path.Base(importPath) == name {
+			workspace = append(workspace, importPath)
+		}
+	}
+	for _, pkg := range fx.s.completionStore.pkgs {
+		if pkg.Name == name && !slices.Contains(workspace, pkg.ImportPath) {
+			others = append(others, pkg.ImportPath)
+		}
+	}
+	slices.Sort(workspace)
+	slices.Sort(others)
+
+	var res []string
+	for _, importPath := range slices.Compact(append(workspace, others...)) {
+		if importPath == fx.unit.pkg.Path() {
+			continue
+		}
+		// Type-check the candidate, to ensure it exists and exports sel.
+		pkg, err := fx.tc.Import(ctx, importPath)
+		if err != nil || pkg == nil || pkg.Name() != name {
+			continue
+		}
+		if obj := pkg.Scope().Lookup(sel); obj == nil || !obj.Exported() {
+			continue
+		}
+		res = append(res, importPath)
+	}
+	return res
+}
+
+// addImportEdits returns the edits adding the import of importPath to f,
+// sorted among the existing imports. The files of overlay are read instead
+// of their content on disk.
+func addImportEdits(fset *token.FileSet, f *ast.File, importPath string, overlay map[string][]byte) []protocol.TextEdit {
+	quoted := strconv.Quote(importPath)
+	var decl *ast.GenDecl
+	for _, d := range f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			decl = gd
+		}
+	}
+
+	insert := func(pos token.Pos, text string) []protocol.TextEdit {
+		return []protocol.TextEdit{{Range: tokenRange(fset, pos, pos, overlay), NewText: text}}
+	}
+	switch {
+	case decl == nil:
+		return insert(f.Name.End(), "\n\nimport "+quoted)
+	case !decl.Lparen.IsValid():
+		// Single import, i.e. `import "std"`
+		spec := decl.Specs[0].(*ast.ImportSpec)
+		p, _ := strconv.Unquote(spec.Path.Value)
+		specs := []string{importSpecString(spec), quoted}
+		sep := "\n\t"
+		if isStdImport(p) != isStdImport(importPath) {
+			sep = "\n\n\t"
+		}
+		if isStdImport(importPath) && !isStdImport(p) ||
+			isStdImport(importPath) == isStdImport(p) && p > importPath {
+			specs[0], specs[1] = specs[1], specs[0]
+		}
+		return []protocol.TextEdit{{
+			Range:   nodeToRange(fset, decl, overlay),
+			NewText: "import (\n\t" + strings.Join(specs, sep) + "\n)",
+		}}
+	case len(decl.Specs) == 0:
+		return insert(decl.Lparen+1, "\n\t"+quoted+"\n")
+	}
+	// Insert among the imports of the same group, standard packages being
+	// grouped before the other ones.
+	var lastOfGroup ast.Spec
+	for _, s := range decl.Specs {
+		spec := s.(*ast.ImportSpec)
+		p, _ := strconv.Unquote(spec.Path.Value)
+		if isStdImport(p) != isStdImport(importPath) {
+			continue
+		}
+		if spec.Path.Value > quoted {
+			return insert(spec.Pos(), quoted+"\n\t")
+		}
+		lastOfGroup = spec
+	}
+	if lastOfGroup != nil {
+		return insert(lastOfGroup.End(), "\n\t"+quoted)
+	}
+	if isStdImport(importPath) {
+		return insert(decl.Specs[0].Pos(), quoted+"\n\n\t")
+	}
+	return insert(decl.Specs[len(decl.Specs)-1].End(), "\n\n\t"+quoted)
+}
+
+// isStdImport returns true if importPath is the path of a standard package,
+// i.e. if its first element isn't a domain name like `gno.land`.
+func isStdImport(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+func importSpecString(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name + " " + spec.Path.Value
+	}
+	return spec.Path.Value
+}
+
+// removeImportFixes returns the action removing the unused import at pos.
+func (fx *fixer) removeImportFixes(pos token.Pos) []protocol.CodeAction {
+	for _, d := range fx.file.Decls {
+		decl, ok := d.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+		for _, s := range decl.Specs {
+			spec := s.(*ast.ImportSpec)
+			if pos < spec.Pos() || pos > spec.End() {
+				continue
+			}
+			var n ast.Node = spec
+			if len(decl.Specs) == 1 {
+				n = decl
+			}
+			return []protocol.CodeAction{{
+				Title:       "Remove import " + spec.Path.Value,
+				IsPreferred: true,
+				Edit:        fx.edit(fx.deleteEdit(n.Pos(), n.End())),
+			}}
+		}
+	}
+	return nil
+}
+
+// removeVariableFixes returns the action removing the unused variable
+// declared at pos, or replacing it by `_` when its declaration can't be
+// removed without removing side effects.
+func (fx *fixer) removeVariableFixes(pos token.Pos) []protocol.CodeAction {
+	path, _ := astutil.PathEnclosingInterval(fx.file, pos, pos)
+	if len(path) < 4 {
+		return nil
+	}
+	id, ok := path[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	remove := "Remove variable " + id.Name
+	blank := "Replace " + id.Name + " with _"
+	replace := func(start, end token.Pos, text string) protocol.TextEdit {
+		return protocol.TextEdit{Range: tokenRange(fx.tc.fset, start, end, fx.tc.overlay), NewText: text}
+	}
+	action := func(title string, edits ...protocol.TextEdit) []protocol.CodeAction {
+		return []protocol.CodeAction{{Title: title, IsPreferred: true, Edit: fx.edit(edits...)}}
+	}
+
+	switch n := path[1].(type) {
+	case *ast.AssignStmt:
+		if n.Tok != token.DEFINE {
+			return nil
+		}
+		if _, ok := path[2].(*ast.TypeSwitchStmt); ok {
+			// `switch x := v.(type)`
+			return action(remove, replace(id.Pos(), n.Rhs[0].Pos(), ""))
+		}
+		if len(n.Lhs) == 1 {
+			if !hasSideEffects(n.Rhs...) {
+				return action(remove, fx.deleteEdit(n.Pos(), n.End()))
+			}
+			return action(blank, replace(id.Pos(), n.Rhs[0].Pos(), "_ = "))
+		}
+		edits := []protocol.TextEdit{replace(id.Pos(), id.End(), "_")}
+		defines := false
+		for _, lhs := range n.Lhs {
+			if lhs, ok := lhs.(*ast.Ident); ok && lhs != id && lhs.Name != "_" && fx.unit.info.Defs[lhs] != nil {
+				defines = true
+			}
+		}
+		if !defines {
+			// `:=` would declare no new variable.
+			edits = append(edits, replace(n.TokPos, n.TokPos+token.Pos(len(":=")), "="))
+		}
+		return action(blank, edits...)
+
+	case *ast.ValueSpec:
+		if len(n.Names) > 1 || hasSideEffects(n.Values...) {
+			return action(blank, replace(id.Pos(), id.End(), "_"))
+		}
+		decl, ok := path[2].(*ast.GenDecl)
+		if !ok {
+			return nil
+		}
+		var del ast.Node = n
+		if len(decl.Specs) == 1 {
+			del = decl
+			if stmt, ok := path[3].(*ast.DeclStmt); ok {
+				del = stmt
+			}
+		}
+		return action(remove, fx.deleteEdit(del.Pos(), del.End()))
+
+	case *ast.RangeStmt:
+		switch {
+		case id == n.Value:
+			if key, ok := n.Key.(*ast.Ident); ok && key.Name == "_" {
+				return action(remove, replace(n.Key.Pos(), n.Range, ""))
+			}
+			return action(remove, replace(n.Key.End(), n.Value.End(), ""))
+		case id == n.Key && n.Value == nil:
+			return action(remove, replace(n.Key.Pos(), n.Range, ""))
+		case id == n.Key:
+			return action(blank, replace(id.Pos(), id.End(), "_"))
+		}
+	}
+	return nil
+}
+
+// hasSideEffects returns true if evaluating exprs may have side effects,
+// i.e. if they contain calls or channel receptions.
+func hasSideEffects(exprs ...ast.Expr) bool {
+	res := false
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				res = true
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					res = true
+				}
+			case *ast.FuncLit:
+				return false // not called
+			}
+			return !res
+		})
+	}
+	return res
+}
+
+// deleteEdit returns the edit deleting the source of fx.file between start
+// and end. The whole lines are deleted if nothing else than spaces and a
+// line comment remains on them.
+func (fx *fixer) deleteEdit(start, end token.Pos) protocol.TextEdit {
+	tokFile := fx.tc.fset.File(start)
+	src := fx.src
+	from, to := tokFile.Offset(start), tokFile.Offset(end)
+
+	lineFrom := from
+	for lineFrom > 0 && (src[lineFrom-1] == ' ' || src[lineFrom-1] == '\t') {
+		lineFrom--
+	}
+	lineTo := to
+	for lineTo < len(src) && (src[lineTo] == ' ' || src[lineTo] == '\t') {
+		lineTo++
+	}
+	if strings.HasPrefix(string(src[lineTo:]), "//") {
+		for lineTo < len(src) && src[lineTo] != '\n' {
+			lineTo++
+		}
+	}
+	if (lineFrom == 0 || src[lineFrom-1] == '\n') && (lineTo == len(src) || src[lineTo] == '\n') {
+		from, to = lineFrom, min(lineTo+1, len(src))
+	}
+	return protocol.TextEdit{Range: tokenRange(fx.tc.fset, tokFile.Pos(from), tokFile.Pos(to), fx.tc.overlay)}
+}
+
+// stubMethodsFixes returns the action declaring the methods of the interface
+// named iface which are missing to the type named typeName, or to its
+// pointer type if ptr is true. Both names are formatted as in type errors.
+func (fx *fixer) stubMethodsFixes(ctx context.Context, ptr bool, typeName, iface string) []protocol.CodeAction {
+	obj, ok := fx.unit.pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil // not declared in the package
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 {
+		return nil
+	}
+	ifaceObj := lookupTypeName(fx.unit.pkg, iface)
+	if ifaceObj == nil {
+		return nil
+	}
+	it, ok := ifaceObj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	var recvType types.Type = named
+	if ptr {
+		recvType = types.NewPointer(named)
+	}
+
+	var missing []*types.Func
+	for i := 0; i < it.NumMethods(); i++ {
+		m := it.Method(i)
+		if !m.Exported() && m.Pkg() != fx.unit.pkg {
+			return nil // can't be implemented outside of its package
+		}
+		if obj, _, _ := types.LookupFieldOrMethod(recvType, false, m.Pkg(), m.Name()); obj == nil {
+			missing = append(missing, m)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Declare the methods after the type, which may be in another file.
+	f := fx.tc.fileAt(obj.Pos())
+	if f == nil {
+		return nil
+	}
+	var decl ast.Decl
+	for _, d := range f.Decls {
+		if d.Pos() <= obj.Pos() && obj.Pos() < d.End() {
+			decl = d
+		}
+	}
+	if decl == nil {
+		return nil
+	}
+
+	// Qualify the types of other packages with their name in f, the missing
+	// imports are added.
+	imported := map[string]string{}
+	for _, spec := range f.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if spec.Name != nil {
+			imported[importPath] = spec.Name.Name
+		} else if pkg, err := fx.tc.Import(ctx, importPath); err == nil && pkg != nil {
+			imported[importPath] = pkg.Name()
+		}
+	}
+	var imports []string
+	qf := func(p *types.Package) string {
+		if p == fx.unit.pkg {
+			return ""
+		}
+		if name, ok := imported[p.Path()]; ok {
+			return name
+		}
+		imported[p.Path()] = p.Name()
+		imports = append(imports, p.Path())
+		return p.Name()
+	}
+
+	recv := strings.ToLower(typeName[:1])
+	var stubs strings.Builder
+	for _, m := range missing {
+		sig := m.Type().(*types.Signature)
+		if strings.Contains(sig.String(), "invalid type") {
+			return nil // the interface doesn't type-check
+		}
+		name := recv
+		for i := 0; i < sig.Params().Len(); i++ {
+			if sig.Params().At(i).Name() == recv {
+				name = "recv"
+			}
+		}
+		fmt.Fprintf(&stubs, "\n\nfunc (%s %s) %s%s {\n\tpanic(\"not implemented\")\n}",
+			name, types.TypeString(recvType, qf), m.Name(), strings.TrimPrefix(types.TypeString(sig, qf), "func"))
+	}
+
+	fset := fx.tc.fset
+	var edits []protocol.TextEdit
+	for _, importPath := range imports {
+		edits = append(edits, addImportEdits(fset, f, importPath, fx.tc.overlay)...)
+	}
+	edits = append(edits, protocol.TextEdit{
+		Range:   tokenRange(fset, decl.End(), decl.End(), fx.tc.overlay),
+		NewText: stubs.String(),
+	})
+	uri := getURI(fset.File(f.Pos()).Name())
+	return []protocol.CodeAction{{
+		Title:       fmt.Sprintf("Implement %s for %s", iface, types.TypeString(recvType, qf)),
+		IsPreferred: true,
+		Edit:        &protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{uri: edits}},
+	}}
+}
+
+// lookupTypeName returns the type named name in the scope of pkg, name being
+// formatted as in type errors, e.g. `Stringer` or `avl.Tree`.
+func lookupTypeName(pkg *types.Package, name string) *types.TypeName {
+	scope := pkg.Scope()
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		qualifier := name[:i]
+		name, scope = name[i+1:], nil
+		for _, imp := range pkg.Imports() {
+			if imp.Name() == qualifier || imp.Path() == qualifier {
+				scope = imp.Scope()
+			}
+		}
+		if scope == nil {
+			return nil
+		}
+	} else if scope.Lookup(name) == nil {
+		scope = types.Universe // e.g. error
+	}
+	obj, _ := scope.Lookup(name).(*types.TypeName)
+	return obj
+}
+
+// organizeImports returns the edits removing the unused imports of fx.file,
+// adding the missing ones which can be resolved unambiguously, and sorting
+// them: standard packages first, then the other ones. fileErrs are the type
+// errors of fx.file.
+func (fx *fixer) organizeImports(ctx context.Context, fileErrs []ErrorInfo) []protocol.TextEdit {
+	f := fx.file
+	var unused []token.Pos
+	added := map[string]bool{}
+	for _, er := range fileErrs {
+		pos := fx.pos(er.Range.Start)
+		switch {
+		case strings.HasPrefix(er.Msg, `"`) && strings.HasSuffix(er.Msg, " and not used"):
+			unused = append(unused, pos)
+		case strings.HasPrefix(er.Msg, "undefined: "):
+			path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+			if len(path) < 2 {
+				continue
+			}
+			id, _ := path[0].(*ast.Ident)
+			sel, ok := path[1].(*ast.SelectorExpr)
+			if !ok || sel.X != id {
+				continue
+			}
+			if candidates := fx.importCandidates(ctx, id.Name, sel.Sel.Name); len(candidates) == 1 {
+				added[candidates[0]] = true
+			}
+		}
+	}
+
+	type importLine struct {
+		path string
+		text string // spec with its comments
+	}
+	var std, others []importLine
+	add := func(importPath, text string) {
+		if isStdImport(importPath) {
+			std = append(std, importLine{importPath, text})
+		} else {
+			others = append(others, importLine{importPath, text})
+		}
+	}
+
+	var first, last *ast.GenDecl
+	for _, d := range f.Decls {
+		decl, ok := d.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+		if first == nil {
+			first = decl
+		}
+		last = decl
+	specs:
+		for _, s := range decl.Specs {
+			spec := s.(*ast.ImportSpec)
+			for _, pos := range unused {
+				if spec.Pos() <= pos && pos <= spec.End() {
+					continue specs
+				}
+			}
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			delete(added, importPath)
+			text := importSpecString(spec)
+			if spec.Doc != nil {
+				text = commentText(spec.Doc, "\n\t") + "\n\t" + text
+			}
+			if spec.Comment != nil {
+				text += " " + commentText(spec.Comment, " ")
+			}
+			add(importPath, text)
+		}
+	}
+	for importPath := range added {
+		add(importPath, strconv.Quote(importPath))
+	}
+
+	var groups []string
+	for _, group := range [][]importLine{std, others} {
+		if len(group) == 0 {
+			continue
+		}
+		slices.SortStableFunc(group, func(a, b importLine) int { return strings.Compare(a.path, b.path) })
+		lines := make([]string, 0, len(group))
+		for i, l := range group {
+			if i > 0 && l.text == group[i-1].text {
+				continue // duplicate
+			}
+			lines = append(lines, "\t"+l.text)
+		}
+		groups = append(groups, strings.Join(lines, "\n"))
+	}
+
+	var text string
+	switch {
+	case len(groups) == 1 && !strings.Contains(groups[0], "\n") && !strings.Contains(groups[0], "//"):
+		text = "import " + strings.TrimPrefix(groups[0], "\t")
+	case len(groups) > 0:
+		text = "import (\n" + strings.Join(groups, "\n\n") + "\n)"
+	}
+
+	fset := fx.tc.fset
+	if first == nil {
+		if text == "" {
+			return nil
+		}
+		return []protocol.TextEdit{{
+			Range:   tokenRange(fset, f.Name.End(), f.Name.End(), fx.tc.overlay),
+			NewText: "\n\n" + text,
+		}}
+	}
+	if text == "" {
+		return []protocol.TextEdit{fx.deleteEdit(first.Pos(), last.End())}
+	}
+	tokFile := fset.File(f.Pos())
+	if string(fx.src[tokFile.Offset(first.Pos()):tokFile.Offset(last.End())]) == text {
+		return nil // already organized
+	}
+	return []protocol.TextEdit{{Range: tokenRange(fset, first.Pos(), last.End(), fx.tc.overlay), NewText: text}}
+}
+
+// commentText returns the comments of cg, joined by sep.
+func commentText(cg *ast.CommentGroup, sep string) string {
+	list := make([]string, 0, len(cg.List))
+	for _, c := range cg.List {
+		list = append(list, c.Text)
+	}
+	return strings.Join(list, sep)
+}
@@ -0,0 +1,30 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestQuickFixMultiByte(t *testing.T) {
+	s, dir := newTestServer(t, map[string]string{
+		"gno.mod": "module gno.land/r/test/qf\n",
+		"qf.gno":  "package qf\n\nfunc F() {\n\t_ = \"😀\"; x := 1\n}\n",
+	})
+	file := testFile(t, s, dir, "qf.gno")
+	// x is at character 11, after the surrogate pair of 😀.
+	x := protocol.Position{Line: 3, Character: 11}
+	actions, err := s.codeActions(context.Background(), s.getSnapshot(), file, protocol.Range{Start: x, End: x}, []protocol.CodeActionKind{protocol.QuickFix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Title != "Remove variable x" {
+		t.Fatalf("got actions %v, want Remove variable x", actions)
+	}
+	edits := actions[0].Edit.Changes[file.URI]
+	want := protocol.Range{Start: x, End: protocol.Position{Line: 3, Character: 17}}
+	if len(edits) != 1 || edits[0].Range != want || edits[0].NewText != "" {
+		t.Errorf("got edits %v, want deletion of %v", edits, want)
+	}
+}
@@ -10,15 +10,17 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
-	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/gnolang/gno/gnovm/pkg/gnomod"
-	"github.com/gnolang/gnopls/internal/builtin"
+	"github.com/gnolang/gnopls/internal/fuzzy"
 	cmap "github.com/orcaman/concurrent-map/v2"
 	"go.lsp.dev/jsonrpc2"
 	"go.lsp.dev/protocol"
@@ -30,36 +32,31 @@ type CompletionStore struct {
 	time time.Time
 
 	pkgs []*Package
+	// byPath indexes pkgs by import path.
+	byPath map[string]*Package
 }
 
-func (cs *CompletionStore) lookupPkg(pkg string) *Package {
-	for _, p := range cs.pkgs {
-		if p.Name == pkg {
-			return p
-		}
-	}
-	return nil
+// lookupPkg returns the package of the given import path.
+func (cs *CompletionStore) lookupPkg(path string) *Package {
+	return cs.byPath[path]
 }
 
-func (cs *CompletionStore) lookupSymbol(pkg, symbol string) *Symbol {
-	for _, p := range cs.pkgs {
-		if p.Name == pkg {
-			for _, s := range p.Symbols {
-				if s.Name == symbol {
-					return s
-				}
-			}
-		}
+// lookupSymbol returns the symbol named symbol of the package of the given
+// import path.
+func (cs *CompletionStore) lookupSymbol(path, symbol string) *Symbol {
+	p := cs.lookupPkg(path)
+	if p == nil {
+		return nil
 	}
-	return nil
+	return p.lookupSymbol(symbol)
 }
 
 func (cs *CompletionStore) lookupSymbolByImports(symbol string, imports []*ast.ImportSpec) *Symbol {
 	for _, spec := range imports {
-		value := spec.Path.Value
-
-		value = value[1 : len(value)-1]                 // remove quotes
-		value = value[strings.LastIndex(value, "/")+1:] // get last part
+		value, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
 
 		s := cs.lookupSymbol(value, symbol)
 		if s != nil {
@@ -73,16 +70,32 @@ func (cs *CompletionStore) lookupSymbolByImports(symbol string, imports []*ast.I
 type Package struct {
 	Name       string
 	ImportPath string
-	Symbols    []*Symbol
+	// Doc is the package documentation.
+	Doc     string
+	Symbols []*Symbol
 
 	Functions  []*Function
 	Methods    cmap.ConcurrentMap[string, []*Method]
 	Structures []*Structure
 
 	TypeCheckResult *TypeCheckResult
+
+	// snapshotID is the ID of the snapshot the package was loaded from.
+	snapshotID uint64
+}
+
+// lookupSymbol returns the symbol of p named name.
+func (p *Package) lookupSymbol(name string) *Symbol {
+	for _, s := range p.Symbols {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
 }
 
 type Symbol struct {
+	// Position is the position of the name of the declaration.
 	Position  token.Position
 	FileURI   uri.URI
 	Name      string
@@ -92,6 +105,7 @@ type Symbol struct {
 }
 
 type Function struct {
+	// Position is the position of the name of the declaration.
 	Position  token.Position
 	FileURI   uri.URI
 	Name      string
@@ -109,6 +123,7 @@ func (f *Function) IsExported() bool {
 }
 
 type Method struct {
+	// Position is the position of the name of the declaration.
 	Position  token.Position
 	FileURI   uri.URI
 	Name      string
@@ -160,30 +175,51 @@ func (s *server) Completion(ctx context.Context, reply jsonrpc2.Replier, req jso
 	uri := params.TextDocument.URI
 
 	// Get snapshot of the current file
-	file, ok := s.snapshot.Get(uri.Filename())
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
 	if !ok {
 		return reply(ctx, nil, errors.New("snapshot not found"))
 	}
-	// Try parsing current file
-	pgf, err := file.ParseGno2(ctx)
-	if err != nil {
+	// Try parsing current file, syntax errors are expected while typing
+	// (e.g. `avl.` misses the selector) so the partial file is used.
+	pgf, _ := file.ParseGno(ctx)
+	if pgf == nil {
 		return reply(ctx, nil, errors.New("cannot parse gno file"))
 	}
 
-	// Calculate offset and line
+	// Calculate offset
 	offset := file.PositionToOffset(params.Position)
-	line := params.Position.Line + 1 // starts at 0, so adding 1
 
-	// Don't show completion items for imports
-	for _, spec := range pgf.File.Imports {
-		if spec.Path.Pos() <= token.Pos(offset) && token.Pos(offset) <= spec.Path.End() {
+	// Complete the import paths
+	if spec := importSpecAt(pgf, offset); spec != nil {
+		return reply(ctx, s.importPathCompletion(snapshot, file, pgf, spec, offset), nil)
+	}
+	// The other trigger characters are only meant for import paths.
+	if pc := params.Context; pc != nil && pc.TriggerKind == protocol.CompletionTriggerKindTriggerCharacter && pc.TriggerCharacter != "." {
+		return reply(ctx, nil, nil)
+	}
+
+	// Completion is based on what precedes the cursor: the identifier
+	// being written, and the selector it belongs to.
+	word := wordBefore(file.Src, offset)
+	start := offset - len(word)
+	if start == 0 || file.Src[start-1] != '.' {
+		// The test files aren't part of the cached package.
+		_, unit, f, err := s.checkFile(ctx, snapshot, file)
+		if err != nil {
 			return reply(ctx, nil, nil)
 		}
+		return reply(ctx, withURI(s.scopeCompletion(unit, f, uri.Filename(), offset, word), uri), nil)
 	}
 
-	// Completion is based on what precedes the cursor.
-	// Find the path to the position before pos.
-	paths, _ := astutil.PathEnclosingInterval(pgf.File, token.Pos(offset-1), token.Pos(offset-1))
+	// Load pkg from cache
+	pkg, ok := s.getPackage(ctx, snapshot, filepath.Dir(string(uri.Filename())))
+	if !ok {
+		return reply(ctx, nil, nil)
+	}
+
+	// Find the path to the operand of the selector, before the dot.
+	paths, _ := astutil.PathEnclosingInterval(pgf.File, token.Pos(start-1), token.Pos(start-1))
 	if paths == nil {
 		return reply(ctx, nil, nil)
 	}
@@ -191,232 +227,406 @@ func (s *server) Completion(ctx context.Context, reply jsonrpc2.Replier, req jso
 	// Debug
 	// slog.Info("COMPLETION", "token", fmt.Sprintf("%s", paths[0]))
 
-	// Load pkg from cache
-	pkg, ok := s.cache.pkgs.Get(filepath.Dir(string(uri.Filename())))
-	if !ok {
-		return reply(ctx, nil, nil)
-	}
-
+	var items []protocol.CompletionItem
 	switch n := paths[0].(type) {
 	case *ast.Ident:
-		_, tv := getTypeAndValue(
-			pgf.Fset,
-			pkg.TypeCheckResult.info, n.Name,
-			int(line),
-			offset,
-		)
-		if tv == nil || tv.Type == nil {
-			return completionPackageIdent(ctx, s, reply, params, pgf, n, true)
+		// Complete the selector's operand, e.g. `a.b` in `a.b.`
+		var expr ast.Expr = n
+		if sel, ok := paths[1].(*ast.SelectorExpr); ok && sel.Sel == n {
+			expr = sel
 		}
-
-		typeStr := tv.Type.String()
-		if typeStr == "invalid type" {
-			return completionPackageIdent(ctx, s, reply, params, pgf, n, false)
+		tv, ok := typeAndValueAt(pkg, pgf, expr)
+		switch {
+		case !ok || tv.Type == nil:
+			items = s.packageIdentItems(ctx, snapshot, pgf, pkg, n, true)
+		case tv.Type.String() == "invalid type":
+			items = s.packageIdentItems(ctx, snapshot, pgf, pkg, n, false)
+		case tv.IsValue():
+			items = s.memberItems(tv.Type, tv.Addressable(), pkg.TypeCheckResult.pkg)
 		}
+	case *ast.CallExpr, *ast.CompositeLit, *ast.IndexExpr, *ast.ParenExpr:
+		tv, ok := typeAndValueAt(pkg, pgf, n.(ast.Expr))
+		if ok && tv.Type != nil && tv.IsValue() {
+			items = s.memberItems(tv.Type, tv.Addressable(), pkg.TypeCheckResult.pkg)
+		}
+	}
+	candidates := make([]candidate, 0, len(items))
+	for _, item := range items {
+		candidates = append(candidates, candidate{item: item, score: exportedScore(item.Label, 1)})
+	}
+	return reply(ctx, withURI(s.completionList(candidates, word), uri), nil)
+}
 
-		m := mode(*tv)
-		if m != "var" {
-			return reply(ctx, nil, nil)
+// defaultCompletionBudget is the default maximum number of completion items.
+const defaultCompletionBudget = 100
+
+// unexportedPenalty weights the score of the unexported members and
+// package-level objects.
+const unexportedPenalty = 0.9
+
+// candidate is a completion item and its score, before fuzzy matching.
+type candidate struct {
+	item  protocol.CompletionItem
+	score float64
+}
+
+// exportedScore returns score, penalized if name is unexported.
+func exportedScore(name string, score float64) float64 {
+	if token.IsExported(name) {
+		return score
+	}
+	return score * unexportedPenalty
+}
+
+// completionList returns the items of the candidates which fuzzy match
+// pattern, sorted by decreasing relevance: their score weighted by how well
+// they match. The list is capped to the completion budget, it's incomplete
+// if candidates were dropped, so that the client asks for the completions
+// again as the pattern grows.
+func (s *server) completionList(candidates []candidate, pattern string) *protocol.CompletionList {
+	matcher := fuzzy.NewMatcher(pattern)
+	var matches []candidate
+	for _, c := range candidates {
+		if c.item.FilterText == "" {
+			c.item.FilterText = c.item.Label
 		}
+		match := matcher.Score(c.item.FilterText)
+		if match == 0 {
+			continue
+		}
+		c.score *= match
+		matches = append(matches, c)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
 
-		if strings.Contains(typeStr, pkg.ImportPath) { // local
-			t := parseType(typeStr, pkg.ImportPath)
-			methods, ok := pkg.Methods.Get(t)
-			if !ok {
-				return reply(ctx, nil, nil)
-			}
-			items := []protocol.CompletionItem{}
-			for _, m := range methods {
-				items = append(items, protocol.CompletionItem{
-					Label:         m.Name,
-					InsertText:    m.Name + "()",
-					Kind:          protocol.CompletionItemKindFunction,
-					Detail:        m.Signature,
-					Documentation: m.Doc,
-				})
+	budget := s.settings.CompletionBudget
+	if budget <= 0 {
+		budget = defaultCompletionBudget
+	}
+	list := &protocol.CompletionList{Items: []protocol.CompletionItem{}}
+	if len(matches) > budget {
+		matches = matches[:budget]
+		list.IsIncomplete = true
+	}
+	for i, m := range matches {
+		m.item.SortText = fmt.Sprintf("%05d", i)
+		list.Items = append(list.Items, m.item)
+	}
+	return list
+}
+
+// typeAndValueAt returns the type and value of expr, an expression of pgf,
+// as recorded in pkg, the type-checked package of pgf. The expression is
+// looked up by position, since pgf and pkg are parsed separately.
+func typeAndValueAt(pkg *Package, pgf *ParsedGnoFile, expr ast.Expr) (types.TypeAndValue, bool) {
+	tcr := pkg.TypeCheckResult
+	if tcr == nil {
+		return types.TypeAndValue{}, false
+	}
+	start := pgf.Fset.Position(expr.Pos()).Offset
+	end := pgf.Fset.Position(expr.End()).Offset
+	for _, f := range tcr.files {
+		tokFile := tcr.fset.File(f.Pos())
+		if tokFile.Name() != pgf.URI.Filename() {
+			continue
+		}
+		if end > tokFile.Size() {
+			break
+		}
+		startPos, endPos := tokFile.Pos(start), tokFile.Pos(end)
+		path, _ := astutil.PathEnclosingInterval(f, startPos, endPos)
+		for _, n := range path {
+			e, ok := n.(ast.Expr)
+			if !ok || e.Pos() != startPos || e.End() != endPos {
+				continue
 			}
-			return reply(ctx, items, nil)
-		}
-		// check imports
-		for _, spec := range pgf.File.Imports {
-			path := spec.Path.Value[1 : len(spec.Path.Value)-1]
-			if strings.Contains(typeStr, path) {
-				parts := strings.Split(path, "/")
-				last := parts[len(parts)-1]
-				pkg := s.completionStore.lookupPkg(last)
-				if pkg == nil {
-					break
-				}
-				t := parseType(typeStr, path)
-				methods, ok := pkg.Methods.Get(t)
-				if !ok {
-					break
-				}
-				items := []protocol.CompletionItem{}
-				for _, m := range methods {
-					items = append(items, protocol.CompletionItem{
-						Label:         m.Name,
-						InsertText:    m.Name + "()",
-						Kind:          protocol.CompletionItemKindFunction,
-						Detail:        m.Signature,
-						Documentation: m.Doc,
-					})
-				}
-				return reply(ctx, items, nil)
+			if tv, ok := tcr.info.Types[e]; ok {
+				return tv, true
 			}
 		}
-		return reply(ctx, nil, nil)
-	case *ast.CallExpr:
-		_, tv := getTypeAndValue(
-			pgf.Fset,
-			pkg.TypeCheckResult.info, types.ExprString(n),
-			int(line),
-			offset,
-		)
-		if tv == nil || tv.Type == nil {
-			return reply(ctx, nil, nil)
+		break
+	}
+	return types.TypeAndValue{}, false
+}
+
+// memberItems returns the completion items of the fields and methods of a
+// value of type t which are accessible from the package from. The methods
+// with a pointer receiver are included if the value is addressable.
+func (s *server) memberItems(t types.Type, addressable bool, from *types.Package) []protocol.CompletionItem {
+	qf := func(p *types.Package) string {
+		if p == from {
+			return ""
 		}
-		typeStr := tv.Type.String()
-		slog.Info(typeStr)
-		if strings.Contains(typeStr, pkg.ImportPath) { // local
-			t := parseType(typeStr, pkg.ImportPath)
-			methods, ok := pkg.Methods.Get(t)
-			if !ok {
-				return reply(ctx, nil, nil)
-			}
-			items := []protocol.CompletionItem{}
-			for _, m := range methods {
-				items = append(items, protocol.CompletionItem{
-					Label:         m.Name,
-					InsertText:    m.Name + "()",
-					Kind:          protocol.CompletionItemKindFunction,
-					Detail:        m.Signature,
-					Documentation: m.Doc,
-				})
+		return p.Name()
+	}
+	accessible := func(obj types.Object) bool {
+		return obj.Exported() || obj.Pkg() == from
+	}
+
+	items := []protocol.CompletionItem{}
+	for _, name := range fieldNames(t) {
+		// Ambiguous selectors, and fields shadowed by methods, aren't
+		// returned as fields.
+		obj, _, _ := types.LookupFieldOrMethod(t, addressable, from, name)
+		field, ok := obj.(*types.Var)
+		if !ok || !accessible(field) {
+			continue
+		}
+		item := protocol.CompletionItem{
+			Label:      name,
+			InsertText: name,
+			Kind:       protocol.CompletionItemKindField,
+			Detail:     types.TypeString(field.Type(), qf),
+		}
+		if data := memberData(t, name); data != nil {
+			item.Data = data
+		}
+		items = append(items, item)
+	}
+
+	mt := t
+	if _, isPtr := t.(*types.Pointer); addressable && !isPtr && !types.IsInterface(t) {
+		mt = types.NewPointer(t)
+	}
+	mset := types.NewMethodSet(mt)
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj()
+		if !accessible(m) {
+			continue
+		}
+		// Methods promoted from embedded types can be shadowed by fields.
+		obj, _, _ := types.LookupFieldOrMethod(t, addressable, from, m.Name())
+		if _, ok := obj.(*types.Func); !ok {
+			continue
+		}
+		sig := m.Type().(*types.Signature)
+		insertText, format := s.callInsertText(m.Name(), sig, from)
+		item := protocol.CompletionItem{
+			Label:            m.Name(),
+			InsertText:       insertText,
+			InsertTextFormat: format,
+			Kind:             protocol.CompletionItemKindMethod,
+			Detail:           "func " + signatureInformation(m.Name(), sig, from).Label,
+		}
+		if data := memberData(t, m.Name()); data != nil {
+			item.Data = data
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// fieldNames returns the names of the fields of the struct t or *t,
+// including the ones promoted from embedded fields.
+func fieldNames(t types.Type) []string {
+	var names []string
+	seen := map[string]bool{}
+	visited := map[types.Type]bool{}
+	var walk func(t types.Type)
+	walk = func(t types.Type) {
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok || visited[t] {
+			return
+		}
+		visited[t] = true
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			if !seen[f.Name()] {
+				seen[f.Name()] = true
+				names = append(names, f.Name())
 			}
-			return reply(ctx, items, nil)
-		}
-		// check imports
-		for _, spec := range pgf.File.Imports {
-			path := spec.Path.Value[1 : len(spec.Path.Value)-1]
-			if strings.Contains(typeStr, path) {
-				parts := strings.Split(path, "/")
-				last := parts[len(parts)-1]
-				pkg := s.completionStore.lookupPkg(last)
-				if pkg == nil {
-					break
-				}
-				t := parseType(typeStr, path)
-				methods, ok := pkg.Methods.Get(t)
-				if !ok {
-					break
-				}
-				items := []protocol.CompletionItem{}
-				for _, m := range methods {
-					items = append(items, protocol.CompletionItem{
-						Label:         m.Name,
-						InsertText:    m.Name + "()",
-						Kind:          protocol.CompletionItemKindFunction,
-						Detail:        m.Signature,
-						Documentation: m.Doc,
-					})
-				}
-				return reply(ctx, items, nil)
+			if f.Embedded() {
+				walk(f.Type())
 			}
 		}
-		return reply(ctx, nil, nil)
-	default:
-		return reply(ctx, nil, nil)
 	}
+	walk(t)
+	return names
 }
 
-func completionPackageIdent(ctx context.Context, s *server, reply jsonrpc2.Replier, params protocol.CompletionParams, pgf *ParsedGnoFile, i *ast.Ident, includeFuncs bool) error {
-	// This function is called not just for packages but also as fallback for unresolved cases.
-	// So, let's also propose builtins first.
-	items := builtin.GetCompletions(i.Name)
+// packageIdentItems returns the completion items of the members of the
+// package named by i, the operand of a selector. This is also the fallback
+// for the operands which can't be resolved.
+func (s *server) packageIdentItems(ctx context.Context, snapshot *Snapshot, pgf *ParsedGnoFile, pkg *Package, i *ast.Ident, includeFuncs bool) []protocol.CompletionItem {
+	var items []protocol.CompletionItem
+	if path := importedPath(pkg, pgf, i); path != "" {
+		if imported := s.lookupPackage(ctx, snapshot, path); imported != nil {
+			items = append(items, s.packageMemberItems(imported, importedTypes(pkg, path), includeFuncs)...)
+		}
+	} else {
+		items = append(items, s.unimportedMemberItems(ctx, snapshot, pgf, i.Name, includeFuncs)...)
+	}
+	return items
+}
 
-	for _, spec := range pgf.File.Imports {
-		path := spec.Path.Value[1 : len(spec.Path.Value)-1]
-		parts := strings.Split(path, "/")
-		last := parts[len(parts)-1]
-		if last == i.Name {
-			pkg := s.completionStore.lookupPkg(last)
-			if pkg != nil {
-				if includeFuncs {
-					for _, f := range pkg.Functions {
-						if !f.IsExported() {
-							continue
-						}
-						items = append(items, protocol.CompletionItem{
-							Label:         f.Name,
-							InsertText:    f.Name + "()",
-							Kind:          protocol.CompletionItemKindFunction,
-							Detail:        f.Signature,
-							Documentation: f.Doc,
-						})
-					}
-				}
-				for _, s := range pkg.Symbols {
-					if s.Kind == "func" {
-						continue
-					}
-					if !unicode.IsUpper(rune(s.Name[0])) {
-						continue
-					}
-					items = append(items, protocol.CompletionItem{
-						Label:         s.Name,
-						InsertText:    s.Name,
-						Kind:          symbolToKind(s.Kind),
-						Detail:        s.Signature,
-						Documentation: s.Doc,
-					})
+// importedTypes returns the type-checked package imported by pkg with the
+// given path, or nil.
+func importedTypes(pkg *Package, path string) *types.Package {
+	if pkg.TypeCheckResult == nil || pkg.TypeCheckResult.pkg == nil {
+		return nil
+	}
+	for _, imp := range pkg.TypeCheckResult.pkg.Imports() {
+		if imp.Path() == path {
+			return imp
+		}
+	}
+	return nil
+}
+
+// packageMemberItems returns the completion items of the exported members of
+// pkg. Functions are included if includeFuncs is true, their parameters are
+// taken from tpkg, the type-checked pkg, which can be nil. Their signature
+// and documentation are left to the resolve of the items.
+func (s *server) packageMemberItems(pkg *Package, tpkg *types.Package, includeFuncs bool) []protocol.CompletionItem {
+	var items []protocol.CompletionItem
+	if includeFuncs {
+		for _, f := range pkg.Functions {
+			if !f.IsExported() {
+				continue
+			}
+			var sig *types.Signature
+			if tpkg != nil {
+				if fn, ok := tpkg.Scope().Lookup(f.Name).(*types.Func); ok {
+					sig = fn.Type().(*types.Signature)
 				}
-				break
 			}
+			insertText, format := s.callInsertText(f.Name, sig, tpkg)
+			items = append(items, protocol.CompletionItem{
+				Label:            f.Name,
+				InsertText:       insertText,
+				InsertTextFormat: format,
+				Kind:             protocol.CompletionItemKindFunction,
+				Data:             &completionData{Pkg: pkg.ImportPath, Name: f.Name},
+			})
+		}
+	}
+	for _, sym := range pkg.Symbols {
+		if sym.Kind == "func" {
+			continue
+		}
+		if !unicode.IsUpper(rune(sym.Name[0])) {
+			continue
+		}
+		items = append(items, protocol.CompletionItem{
+			Label:      sym.Name,
+			InsertText: sym.Name,
+			Kind:       symbolToKind(sym.Kind),
+			Data:       &completionData{Pkg: pkg.ImportPath, Name: sym.Name},
+		})
+	}
+	return items
+}
+
+// unimportedMemberItems returns the completion items of the exported members
+// of the packages named name which are not imported by pgf, from the
+// workspace and from GNOROOT. Accepting an item adds the import of its
+// package, which is shown in the detail, as several packages can have the
+// same name (e.g. forks of avl).
+func (s *server) unimportedMemberItems(ctx context.Context, snapshot *Snapshot, pgf *ParsedGnoFile, name string, includeFuncs bool) []protocol.CompletionItem {
+	self := importPathFromGnoMod(filepath.Dir(pgf.URI.Filename()))
+	seen := map[string]bool{self: true}
+	var tc *TypeCheck
+	var items []protocol.CompletionItem
+	for _, pkg := range append(s.workspacePackages(snapshot), s.completionStore.pkgs...) {
+		if pkg.Name != name || seen[pkg.ImportPath] {
+			continue
+		}
+		seen[pkg.ImportPath] = true
+		// The parameters of the functions are only needed by snippets.
+		var tpkg *types.Package
+		if s.snippets && includeFuncs {
+			if tc == nil {
+				tc, _ = s.newTypeCheck(snapshot)
+			}
+			tpkg, _ = tc.Import(ctx, pkg.ImportPath)
+		}
+		var edits []protocol.TextEdit
+		if !s.resolveImports {
+			edits = addImportEdits(pgf.Fset, pgf.File, pkg.ImportPath, pgf.overlay())
+		}
+		for _, item := range s.packageMemberItems(pkg, tpkg, includeFuncs) {
+			item.Detail = fmt.Sprintf("(from %q)", pkg.ImportPath)
+			item.AdditionalTextEdits = edits
+			item.Data.(*completionData).Import = true
+			items = append(items, item)
 		}
 	}
+	return items
+}
 
-	return reply(ctx, items, nil)
+// wordBefore returns the identifier ending at offset in src.
+func wordBefore(src []byte, offset int) string {
+	start := min(offset, len(src))
+	for start > 0 {
+		r, size := utf8.DecodeLastRune(src[:start])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		start -= size
+	}
+	return string(src[start:min(offset, len(src))])
 }
 
 // End
 // ------------------------------------------------------
 
 func InitCompletionStore(dirs []string) *CompletionStore {
-	pkgs := []*Package{}
-
-	if len(dirs) == 0 {
-		return &CompletionStore{
-			pkgs: pkgs,
-			time: time.Now(),
-		}
+	cs := &CompletionStore{
+		pkgs:   []*Package{},
+		byPath: map[string]*Package{},
+		time:   time.Now(),
 	}
 
-	pkgDirs, err := ListGnoPackages(dirs)
-	if err != nil {
-		// Ignore error
-		return &CompletionStore{
-			pkgs: pkgs,
-			time: time.Now(),
-		}
-	}
-
-	for _, p := range pkgDirs {
-		pkg, err := PackageFromDir(p, false)
+	for _, dir := range dirs {
+		pkgDirs, err := ListGnoPackages([]string{dir})
 		if err != nil {
+			// Ignore error
 			continue
 		}
-		pkgs = append(pkgs, pkg)
+
+		for _, p := range pkgDirs {
+			pkg, err := PackageFromDir(p, false)
+			if err != nil {
+				continue
+			}
+			pkg.ImportPath = importPathInRoot(dir, p)
+			cs.pkgs = append(cs.pkgs, pkg)
+			if _, ok := cs.byPath[pkg.ImportPath]; !ok {
+				cs.byPath[pkg.ImportPath] = pkg
+			}
+		}
 	}
+	return cs
+}
 
-	return &CompletionStore{
-		pkgs: pkgs,
-		time: time.Now(),
+// importPathInRoot returns the import path of the package located in dir,
+// under the root directory. It's based on the closest `gno.mod` if any, like
+// for the examples, otherwise on the position of dir under root, like for
+// the standard libraries (e.g. `crypto/sha256`).
+func importPathInRoot(root, dir string) string {
+	if path := importPathFromGnoMod(dir); path != "" {
+		return path
 	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return dir
+	}
+	return filepath.ToSlash(rel)
 }
 
 func PackageFromDir(path string, onlyExports bool) (*Package, error) {
-	files, err := ListGnoFiles(path)
+	return packageFromDir(path, onlyExports, nil)
+}
+
+// packageFromDir is like PackageFromDir, but files present in overlay are
+// read from it rather than from disk.
+func packageFromDir(path string, onlyExports bool, overlay map[string][]byte) (*Package, error) {
+	files, err := listGnoFiles(path, overlay)
 	if err != nil {
 		return nil, err
 	}
@@ -426,7 +636,7 @@ func PackageFromDir(path string, onlyExports bool) (*Package, error) {
 	var symbols []*Symbol
 	var functions []*Function
 	var structures []*Structure
-	var packageName string
+	var packageName, packageDoc string
 	methods := cmap.New[[]*Method]()
 	for _, fname := range files {
 		if strings.HasSuffix(fname, "_test.gno") ||
@@ -437,22 +647,26 @@ func PackageFromDir(path string, onlyExports bool) (*Package, error) {
 		if err != nil {
 			return nil, err
 		}
-		bsrc, err := os.ReadFile(absPath)
+		bsrc, err := readFile(absPath, overlay)
 		if err != nil {
 			return nil, err
 		}
 		text := string(bsrc)
 		// Parse the file and create an AST.
 		fset := token.NewFileSet()
-		file, err := parser.ParseFile(fset, fname, text, parser.ParseComments)
-		if err != nil {
-			return nil, err
+		file, _ := parser.ParseFile(fset, fname, text, parser.ParseComments)
+		if file == nil {
+			return nil, fmt.Errorf("cannot parse %s", fname)
 		}
+		// Files being edited may not parse, their partial AST is used.
 		if onlyExports { // Trim AST to exported declarations only.
 			ast.FileExports(file)
 		}
 
 		packageName = file.Name.Name
+		if packageDoc == "" {
+			packageDoc = file.Doc.Text()
+		}
 		ast.Inspect(file, func(n ast.Node) bool {
 			var symbol *Symbol
 
@@ -464,7 +678,7 @@ func PackageFromDir(path string, onlyExports bool) (*Package, error) {
 						case *ast.StarExpr:
 							k := fmt.Sprintf("%s", rt.X)
 							m := &Method{
-								Position:  fset.Position(t.Pos()),
+								Position:  fset.Position(t.Name.Pos()),
 								FileURI:   getURI(absPath),
 								Name:      t.Name.Name,
 								Arguments: []*Field{}, // TODO: fill args
@@ -481,7 +695,7 @@ func PackageFromDir(path string, onlyExports bool) (*Package, error) {
 						case *ast.Ident:
 							k := fmt.Sprintf("%s", rt.Name)
 							m := &Method{
-								Position:  fset.Position(t.Pos()),
+								Position:  fset.Position(t.Name.Pos()),
 								FileURI:   getURI(absPath),
 								Name:      t.Name.Name,
 								Arguments: []*Field{}, // TODO: fill args
@@ -499,7 +713,7 @@ func PackageFromDir(path string, onlyExports bool) (*Package, error) {
 					}
 				} else { // func
 					f := &Function{
-						Position:  fset.Position(t.Pos()),
+						Position:  fset.Position(t.Name.Pos()),
 						FileURI:   getURI(absPath),
 						Name:      t.Name.Name,
 						Arguments: []*Field{}, // TODO: fill args
@@ -510,6 +724,7 @@ func PackageFromDir(path string, onlyExports bool) (*Package, error) {
 					functions = append(functions, f)
 				}
 				symbol = function(n, text)
+				symbol.Position = fset.Position(t.Name.Pos())
 			case *ast.GenDecl:
 				for _, spec := range t.Specs {
 					switch s := spec.(type) {
@@ -529,17 +744,44 @@ func PackageFromDir(path string, onlyExports bool) (*Package, error) {
 						}
 					}
 				}
-				symbol = declaration(n, text)
+				for _, symbol := range declarations(fset, n, text) {
+					symbol.FileURI = getURI(absPath)
+					symbols = append(symbols, symbol)
+				}
 			}
 
 			if symbol != nil {
 				symbol.FileURI = getURI(absPath)
-				symbol.Position = fset.Position(n.Pos())
 				symbols = append(symbols, symbol)
 			}
 
 			return true
 		})
+
+		// Package level constants and variables
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || (gd.Tok != token.CONST && gd.Tok != token.VAR) {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				vs := spec.(*ast.ValueSpec)
+				doc := vs.Doc.Text()
+				if doc == "" {
+					doc = gd.Doc.Text()
+				}
+				for _, name := range vs.Names {
+					symbols = append(symbols, &Symbol{
+						Position:  fset.Position(name.Pos()),
+						FileURI:   getURI(absPath),
+						Name:      name.Name,
+						Doc:       doc,
+						Signature: gd.Tok.String() + " " + text[vs.Pos()-1:vs.End()-1],
+						Kind:      gd.Tok.String(),
+					})
+				}
+			}
+		}
 	}
 	return &Package{
 		Name: packageName,
@@ -549,6 +791,7 @@ func PackageFromDir(path string, onlyExports bool) (*Package, error) {
 			}
 			return gm.Module.Mod.Path
 		}(),
+		Doc:        packageDoc,
 		Symbols:    symbols,
 		Functions:  functions,
 		Methods:    methods,
@@ -586,7 +829,10 @@ func getSymbols(fname string) []*Symbol {
 		case *ast.FuncDecl:
 			symbol = function(n, text)
 		case *ast.GenDecl:
-			symbol = declaration(n, text)
+			for _, symbol := range declarations(fset, n, text) {
+				symbol.FileURI = getURI(absPath)
+				symbols = append(symbols, symbol)
+			}
 		}
 
 		if symbol != nil {
@@ -601,22 +847,30 @@ func getSymbols(fname string) []*Symbol {
 	return symbols
 }
 
-func declaration(n ast.Node, source string) *Symbol {
-	sym, _ := n.(*ast.GenDecl)
+// declarations returns the symbols of the types declared by n, all the
+// specs of grouped declarations like `type ( ... )` included.
+func declarations(fset *token.FileSet, n ast.Node, source string) []*Symbol {
+	decl, _ := n.(*ast.GenDecl)
 
-	for _, spec := range sym.Specs {
+	var symbols []*Symbol
+	for _, spec := range decl.Specs {
 		switch t := spec.(type) {
 		case *ast.TypeSpec:
-			return &Symbol{
+			doc := t.Doc.Text()
+			if !decl.Lparen.IsValid() {
+				doc = decl.Doc.Text()
+			}
+			symbols = append(symbols, &Symbol{
+				Position:  fset.Position(t.Name.Pos()),
 				Name:      t.Name.Name,
-				Doc:       sym.Doc.Text(),
+				Doc:       doc,
 				Signature: strings.Split(source[t.Pos()-1:t.End()-1], " {")[0],
 				Kind:      typeName(*t),
-			}
+			})
 		}
 	}
 
-	return nil
+	return symbols
 }
 
 func function(n ast.Node, source string) *Symbol {
@@ -0,0 +1,34 @@
+package lsp
+
+import (
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestCompletionTestFile(t *testing.T) {
+	s, dir := newTestServer(t, map[string]string{
+		"gno.mod":     "module gno.land/r/test/ct\n",
+		"ct.gno":      "package ct\n\nfunc Helper() int { return 1 }\n",
+		"ct_test.gno": "package ct\n\nvar helperWant = 1\n\nfunc testHelper() bool {\n\treturn Hel\n}\n",
+	})
+	file := testFile(t, s, dir, "ct_test.gno")
+	params := protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: file.URI},
+			Position:     protocol.Position{Line: 5, Character: 11},
+		},
+	}
+	var list protocol.CompletionList
+	testCall(t, s.Completion, "textDocument/completion", params, &list)
+	labels := map[string]bool{}
+	for _, item := range list.Items {
+		labels[item.Label] = true
+	}
+	// Both the package and the test file objects are in scope.
+	for _, want := range []string{"Helper", "helperWant"} {
+		if !labels[want] {
+			t.Errorf("%s not found in %v", want, list.Items)
+		}
+	}
+}
@@ -2,42 +2,109 @@ package lsp
 
 import (
 	"context"
+	"log/slog"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"go.lsp.dev/jsonrpc2"
 	"go.lsp.dev/protocol"
 )
 
-func (s *server) getTranspileDiagnostics(file *GnoFile) ([]protocol.Diagnostic, error) {
-	errors, err := s.TranspileAndBuild(file)
+// diagnose type-checks the package located in dir along with its test
+// files, and returns the errors found in the files of dir by file name.
+func (s *server) diagnose(ctx context.Context, snapshot *Snapshot, dir string) (map[string][]ErrorInfo, error) {
+	tc, errs := s.newTypeCheck(snapshot)
+	units, err := checkPackageDir(ctx, tc, dir)
 	if err != nil {
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	if pkg, ok := s.cache.pkgs.Get(filepath.Dir(string(file.URI.Filename()))); ok {
-		filename := filepath.Base(file.URI.Filename())
-		for _, er := range pkg.TypeCheckResult.Errors() {
-			// Skip errors from other files in the same package
-			if !strings.HasSuffix(er.FileName, filename) {
-				continue
-			}
-			errors = append(errors, er)
+	res := map[string][]ErrorInfo{}
+	for _, unit := range units {
+		for _, er := range unit.syntaxErrs {
+			res[er.FileName] = append(res[er.FileName], er)
+		}
+	}
+	// errs holds the errors of all the type-checked packages, including
+	// the imported ones.
+	for _, er := range typeErrors(*errs, tc.files, tc.overlay) {
+		if filepath.Dir(er.FileName) != dir {
+			continue
 		}
+		if isTestNativeImportError(er) {
+			continue
+		}
+		res[er.FileName] = append(res[er.FileName], er)
+	}
+	// The gno VM rejects constructs accepted by the type-checker. Its
+	// preprocessor stops at the first error, so it only runs once the
+	// package has no other error.
+	preprocess := true
+	for filename := range res {
+		if !strings.HasSuffix(filename, "_test.gno") && !strings.HasSuffix(filename, "_filetest.gno") {
+			preprocess = false
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	for _, er := range checkGnoPackage(ctx, snapshot, tc, dir, importPathFromGnoMod(dir), preprocess) {
+		res[er.FileName] = append(res[er.FileName], er)
+	}
+	return res, nil
+}
+
+// testNativePackages are the Go packages provided natively by the gno test
+// runner, which can be imported by the test files but can't be type-checked.
+var testNativePackages = map[string]bool{
+	"os":               true,
+	"fmt":              true,
+	"log":              true,
+	"crypto/rand":      true,
+	"crypto/md5":       true,
+	"crypto/sha1":      true,
+	"encoding/binary":  true,
+	"encoding/json":    true,
+	"encoding/xml":     true,
+	"internal/os_test": true,
+	"math/big":         true,
+	"math/rand":        true,
+}
+
+var importErrorRe = regexp.MustCompile(`^could not import (\S+) `)
 
+// isTestNativeImportError returns true if er is the import error of a test
+// file importing a package in testNativePackages.
+func isTestNativeImportError(er ErrorInfo) bool {
+	if !strings.HasSuffix(er.FileName, "_test.gno") && !strings.HasSuffix(er.FileName, "_filetest.gno") {
+		return false
+	}
+	m := importErrorRe.FindStringSubmatch(er.Msg)
+	return m != nil && testNativePackages[m[1]]
+}
+
+func toDiagnostics(errors []ErrorInfo) []protocol.Diagnostic {
 	diagnostics := make([]protocol.Diagnostic, 0) // Init required for JSONRPC to send an empty array
 	for _, er := range errors {
+		severity := er.Severity
+		if severity == 0 {
+			severity = protocol.DiagnosticSeverityError
+		}
 		diagnostics = append(diagnostics, protocol.Diagnostic{
-			Range:    *posToRange(er.Line, er.Span),
-			Severity: protocol.DiagnosticSeverityError,
-			Source:   "gnopls",
-			Message:  er.Msg,
-			Code:     er.Tool,
+			Range:              er.Range,
+			Severity:           severity,
+			Source:             "gnopls",
+			Message:            er.Msg,
+			Code:               er.Tool,
+			RelatedInformation: er.Related,
 		})
 	}
-
-	return diagnostics, nil
+	return diagnostics
 }
 
 func (s *server) publishDiagnostics(ctx context.Context, conn jsonrpc2.Conn, file *GnoFile, diagnostics []protocol.Diagnostic) error {
@@ -50,3 +117,75 @@ func (s *server) publishDiagnostics(ctx context.Context, conn jsonrpc2.Conn, fil
 		},
 	)
 }
+
+// diagnosticsDelay is how long live diagnostics wait for the edits to
+// settle before type-checking.
+const diagnosticsDelay = 300 * time.Millisecond
+
+// diagnosticsRun is a pending or in-flight check scheduled by
+// scheduleDiagnostics. Runs are compared by pointer, so that a run only
+// forgets itself once done.
+type diagnosticsRun struct {
+	cancel context.CancelFunc
+}
+
+// scheduleDiagnostics type-checks the package located in dir once no other
+// change happened for diagnosticsDelay, and publishes the errors of each
+// file of the package open in snapshot. A pending or in-flight check
+// of the same package is cancelled.
+func (s *server) scheduleDiagnostics(snapshot *Snapshot, dir string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &diagnosticsRun{cancel: cancel}
+	s.diagnosticsMu.Lock()
+	if prev, ok := s.diagnosticsCancel[dir]; ok {
+		prev.cancel()
+	}
+	s.diagnosticsCancel[dir] = run
+	s.diagnosticsMu.Unlock()
+
+	go func() {
+		defer func() {
+			cancel()
+			// Forget the run, unless a newer one replaced it.
+			s.diagnosticsMu.Lock()
+			if s.diagnosticsCancel[dir] == run {
+				delete(s.diagnosticsCancel, dir)
+			}
+			s.diagnosticsMu.Unlock()
+		}()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(diagnosticsDelay):
+		}
+
+		errors, err := s.diagnose(ctx, snapshot, dir)
+		if err != nil {
+			return
+		}
+		for _, file := range snapshot.Files() {
+			if filepath.Dir(file.URI.Filename()) != dir {
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			diagnostics := toDiagnostics(errors[file.URI.Filename()])
+			if err := s.publishDiagnostics(ctx, s.conn, file, diagnostics); err != nil {
+				slog.Error("DIAGNOSTICS", "error", err)
+			}
+		}
+	}()
+}
+
+// cancelDiagnostics cancels the pending or in-flight check of the package
+// located in dir.
+func (s *server) cancelDiagnostics(dir string) {
+	s.diagnosticsMu.Lock()
+	defer s.diagnosticsMu.Unlock()
+	if run, ok := s.diagnosticsCancel[dir]; ok {
+		run.cancel()
+		delete(s.diagnosticsCancel, dir)
+	}
+}
@@ -20,7 +20,7 @@ func (s *server) Formatting(ctx context.Context, reply jsonrpc2.Replier, req jso
 	}
 
 	uri := params.TextDocument.URI
-	file, ok := s.snapshot.Get(uri.Filename())
+	file, ok := s.getSnapshot().Get(uri.Filename())
 	if !ok {
 		return reply(ctx, nil, errors.New("snapshot not found"))
 	}
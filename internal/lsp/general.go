@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"path/filepath"
 
@@ -20,50 +21,85 @@ func (s *server) DidOpen(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 
 	uri := params.TextDocument.URI
 	file := &GnoFile{
-		URI: uri,
-		Src: []byte(params.TextDocument.Text),
+		URI:     uri,
+		Src:     []byte(params.TextDocument.Text),
+		Version: params.TextDocument.Version,
 	}
-	s.snapshot.file.Set(uri.Filename(), file)
+	snapshot, _ := s.updateSnapshot(func(snapshot *Snapshot) (*Snapshot, error) {
+		return snapshot.WithFile(file), nil
+	})
 
 	slog.Info("open " + string(params.TextDocument.URI.Filename()))
-	s.UpdateCache(filepath.Dir(string(params.TextDocument.URI.Filename())))
-	diagnostics, err := s.getTranspileDiagnostics(file)
+	// The file may be new, in a new package.
+	s.invalidateWorkspace()
+	dir := filepath.Dir(string(params.TextDocument.URI.Filename()))
+	s.UpdateCache(ctx, snapshot, dir)
+	errs, err := s.diagnose(ctx, snapshot, dir)
 	if err != nil {
 		return sendParseError(ctx, reply, err)
 	}
+	diagnostics := toDiagnostics(errs[uri.Filename()])
 	notification := s.publishDiagnostics(ctx, s.conn, file, diagnostics)
 	return reply(ctx, notification, nil)
 }
 
 func (s *server) DidClose(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
-	var params protocol.DidChangeTextDocumentParams
+	var params protocol.DidCloseTextDocumentParams
 	if err := json.Unmarshal(req.Params(), &params); err != nil {
 		return sendParseError(ctx, reply, err)
 	}
 
+	s.updateSnapshot(func(snapshot *Snapshot) (*Snapshot, error) {
+		return snapshot.WithoutFile(params.TextDocument.URI.Filename()), nil
+	})
+	s.semanticTokensMu.Lock()
+	delete(s.semanticTokensResults, params.TextDocument.URI.Filename())
+	s.semanticTokensMu.Unlock()
+
 	slog.Info("close" + string(params.TextDocument.URI.Filename()))
+	// The file may have been deleted, or never saved.
+	s.invalidateWorkspace()
+	diskSources.forget(params.TextDocument.URI.Filename())
 	return reply(ctx, s.conn.Notify(ctx, protocol.MethodTextDocumentDidClose, nil), nil)
 }
 
+// didChangeParams is protocol.DidChangeTextDocumentParams, with optional
+// ranges in content changes.
+type didChangeParams struct {
+	TextDocument   protocol.VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []ContentChange                          `json:"contentChanges"`
+}
+
 func (s *server) DidChange(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
-	var params protocol.DidChangeTextDocumentParams
+	var params didChangeParams
 	if err := json.Unmarshal(req.Params(), &params); err != nil {
 		return sendParseError(ctx, reply, err)
 	}
 
 	uri := params.TextDocument.URI
-	_, ok := s.snapshot.Get(uri.Filename())
-	if !ok {
-		return reply(ctx, nil, errors.New("snapshot not found"))
-	}
-
-	file := &GnoFile{
-		URI: uri,
-		Src: []byte(params.ContentChanges[0].Text),
+	snapshot, err := s.updateSnapshot(func(snapshot *Snapshot) (*Snapshot, error) {
+		file, ok := snapshot.Get(uri.Filename())
+		if !ok {
+			return nil, errors.New("snapshot not found")
+		}
+		version := params.TextDocument.Version
+		if version <= file.Version {
+			// Changes are applied in order, an older version means the
+			// change was already applied or is out of order.
+			return nil, fmt.Errorf("stale change of %s: version %d, expected > %d", uri.Filename(), version, file.Version)
+		}
+		file, err := file.ApplyChanges(params.ContentChanges, version)
+		if err != nil {
+			return nil, err
+		}
+		return snapshot.WithFile(file), nil
+	})
+	if err != nil {
+		return reply(ctx, nil, err)
 	}
-	s.snapshot.file.Set(uri.Filename(), file)
 
 	slog.Info("change " + string(params.TextDocument.URI.Filename()))
+	s.scheduleDiagnostics(snapshot, filepath.Dir(uri.Filename()))
 	return reply(ctx, nil, nil)
 }
 
@@ -74,19 +110,26 @@ func (s *server) DidSave(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 	}
 
 	uri := params.TextDocument.URI
-	file, ok := s.snapshot.Get(uri.Filename())
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
 	if !ok {
 		return reply(ctx, nil, errors.New("snapshot not found"))
 	}
 
 	slog.Info("save " + string(uri.Filename()))
-	s.UpdateCache(filepath.Dir(string(params.TextDocument.URI.Filename())))
+	s.invalidateWorkspace()
+	diskSources.forget(uri.Filename())
+	// Saving publishes all the diagnostics, which must not be overwritten
+	// by pending live diagnostics.
+	dir := filepath.Dir(string(params.TextDocument.URI.Filename()))
+	s.cancelDiagnostics(dir)
+	s.UpdateCache(ctx, snapshot, dir)
 	diagnostics := []protocol.Diagnostic{}
-	transpileDiags, err := s.getTranspileDiagnostics(file)
+	errs, err := s.diagnose(ctx, snapshot, dir)
 	if err == nil {
-		diagnostics = append(diagnostics, transpileDiags...)
+		diagnostics = append(diagnostics, toDiagnostics(errs[uri.Filename()])...)
 	} else {
-		slog.Error("TRANSPILE", "error", err)
+		slog.Error("TYPECHECK", "error", err)
 	}
 	diags, err := tools.Lint(ctx, s.conn, params.Text, uri)
 	if err == nil {
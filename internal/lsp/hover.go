@@ -8,8 +8,6 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
-	"log/slog"
-	"path/filepath"
 	"strings"
 
 	"go.lsp.dev/jsonrpc2"
@@ -29,675 +27,206 @@ func (s *server) Hover(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2
 	}
 
 	uri := params.TextDocument.URI
-
-	// Get snapshot of the current file
-	file, ok := s.snapshot.Get(uri.Filename())
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
 	if !ok {
 		return reply(ctx, nil, errors.New("snapshot not found"))
 	}
-	// Try parsing current file
-	pgf, err := file.ParseGno(ctx)
-	if err != nil {
-		return reply(ctx, nil, errors.New("cannot parse gno file"))
-	}
-	// Load pkg from cache
-	pkg, ok := s.cache.pkgs.Get(filepath.Dir(string(params.TextDocument.URI.Filename())))
-	if !ok {
-		return reply(ctx, nil, nil)
-	}
-	info := pkg.TypeCheckResult.info
-
-	// Calculate offset and line
-	offset := file.PositionToOffset(params.Position)
-	line := params.Position.Line + 1 // starts at 0, so adding 1
-
-	slog.Info("hover", "line", line, "offset", offset)
-
-	// Handle hovering over import paths
-	for _, spec := range pgf.File.Imports {
-		// Inclusive of the end points
-		if spec.Path.Pos() <= token.Pos(offset) && token.Pos(offset) <= spec.Path.End() {
-			return hoverImport(ctx, reply, pgf, params, spec)
-		}
-	}
-
-	// Get path enclosing
-	paths := pathEnclosingObjNode(pgf.File, token.Pos(offset))
-	if len(paths) < 2 {
-		return reply(ctx, nil, nil)
-	}
-
-	switch n := paths[0].(type) {
-	case *ast.Ident:
-		_, tv := getTypeAndValue(
-			pkg.TypeCheckResult.fset,
-			info, n.Name,
-			int(line),
-			offset,
-		)
-		if tv == nil || tv.Type == nil {
-			switch t := paths[1].(type) {
-			case *ast.FuncDecl:
-				if t.Recv != nil {
-					return hoverMethodDecl(ctx, reply, params, pkg, n, t)
-				}
-				return hoverFuncDecl(ctx, reply, params, pkg, n)
-			case *ast.SelectorExpr:
-				return hoverSelectorExpr(ctx, s, reply, params, pgf, pkg, paths, n, t, int(line))
-			default:
-				return reply(ctx, protocol.Hover{
-					Contents: protocol.MarkupContent{
-						Kind:  protocol.Markdown,
-						Value: FormatHoverContent(n.Name, ""),
-					},
-					Range: posToRange(
-						int(params.Position.Line),
-						[]int{int(n.Pos()), int(n.End())},
-					),
-				}, nil)
-			}
-		}
-		typeStr := tv.Type.String()
-		m := mode(*tv)
-		isPackageLevelGlobal := strings.Contains(typeStr, pkg.ImportPath) // better name
-
-		// Handle builtins
-		if doc, ok := isBuiltin(n, tv); ok {
-			return hoverBuiltinTypes(ctx, reply, params, n, tv, m, doc)
-		}
-
-		// local var
-		if (isPackageLevelGlobal || !strings.Contains(typeStr, "gno.land")) && m == "var" {
-			return hoverLocalVar(ctx, reply, params, pkg, n, tv, m, typeStr, isPackageLevelGlobal)
-		}
 
-		// local type
-		if isPackageLevelGlobal && m == "type" {
-			typeStr := parseType(typeStr, pkg.ImportPath)
-			return hoverPackageLevelTypes(ctx, reply, params, pkg, n, tv, m, typeStr)
-		}
-
-		// local global and is value
-		if m == "value" {
-			typeStr := parseType(typeStr, pkg.ImportPath)
-			return hoverPackageLevelValue(ctx, reply, params, pkg, n, tv, m, typeStr, isPackageLevelGlobal)
-		}
-
-		// if var of type imported package
-		var header string
-		if strings.Contains(typeStr, "gno.land/") {
-			for _, spec := range pgf.File.Imports {
-				path := spec.Path.Value[1 : len(spec.Path.Value)-1]
-				if strings.Contains(typeStr, path) {
-					parts := strings.Split(path, "/")
-					last := parts[len(parts)-1]
-					t := strings.Replace(typeStr, path, last, 1)
-					header = fmt.Sprintf("%s %s %s", m, n.Name, t)
-					break
-				}
-			}
-		} else { // rest of the cases
-			header = fmt.Sprintf("%s %s %s", m, n.Name, typeStr)
-		}
-
-		// Handles rest of the cases
-		// TODO: improve?
-		return reply(ctx, protocol.Hover{
-			Contents: protocol.MarkupContent{
-				Kind:  protocol.Markdown,
-				Value: FormatHoverContent(header, ""),
-			},
-			Range: posToRange(
-				int(params.Position.Line),
-				[]int{int(n.Pos()), int(n.End())},
-			),
-		}, nil)
-	default:
-		return reply(ctx, nil, nil)
+	hover, err := s.hover(ctx, snapshot, file, params.Position)
+	if err != nil {
+		return reply(ctx, nil, err)
 	}
+	return reply(ctx, hover, nil)
 }
 
-func hoverSelectorExpr(ctx context.Context, s *server, reply jsonrpc2.Replier, params protocol.HoverParams, pgf *ParsedGnoFile, pkg *Package, paths []ast.Node, i *ast.Ident, sel *ast.SelectorExpr, line int) error {
-	exprStr := types.ExprString(sel)
-
-	parent := sel.X
-	parentStr := types.ExprString(parent)
-
-	_, tv := getTypeAndValueLight(
-		pkg.TypeCheckResult.fset,
-		pkg.TypeCheckResult.info,
-		exprStr,
-		int(line),
-	)
-	if tv == nil || tv.Type == nil {
-		return reply(ctx, nil, nil)
+// hover returns the declaration, the documentation and the package of the
+// object denoted by the identifier at pos in file, or nil if there's none.
+func (s *server) hover(ctx context.Context, snapshot *Snapshot, file *GnoFile, pos protocol.Position) (*protocol.Hover, error) {
+	tc, unit, f, err := s.checkFile(ctx, snapshot, file)
+	if err != nil {
+		return nil, err
 	}
-	tvStr := tv.Type.String()
-
-	_, tvParent := getTypeAndValueLight(
-		pkg.TypeCheckResult.fset,
-		pkg.TypeCheckResult.info,
-		parentStr,
-		int(line),
-	)
-	if tvParent == nil { // can be import
-		for _, spec := range pgf.File.Imports {
-			path := spec.Path.Value[1 : len(spec.Path.Value)-1]
-			parts := strings.Split(path, "/")
-			last := parts[len(parts)-1]
-			if last == i.Name { // hover on pkg name
-				header := fmt.Sprintf("package %s (%s)", last, spec.Path.Value)
-				body := func() string {
-					if strings.HasPrefix(path, "gno.land/") {
-						return fmt.Sprintf("[```%s``` on gno.land](https://%s)", last, path)
-					}
-					return fmt.Sprintf("[```%s``` on gno.land](https://gno.land)", last)
-				}()
-				return reply(ctx, protocol.Hover{
-					Contents: protocol.MarkupContent{
-						Kind:  protocol.Markdown,
-						Value: FormatHoverContent(header, body),
-					},
-					Range: posToRange(
-						int(params.Position.Line),
-						[]int{int(i.Pos()), int(i.End())},
-					),
-				}, nil)
-			} else if last == parentStr { // hover on package symbol
-				symbol := s.completionStore.lookupSymbol(parentStr, i.Name)
-				if symbol == nil {
-					break
-				}
-
-				return reply(ctx, protocol.Hover{
-					Contents: protocol.MarkupContent{
-						Kind:  protocol.Markdown,
-						Value: symbol.String(),
-					},
-					Range: posToRange(
-						int(params.Position.Line),
-						[]int{int(i.Pos()), int(i.End())},
-					),
-				}, nil)
-			}
-		}
-		return reply(ctx, nil, nil)
+	offset := file.PositionToOffset(pos)
+	if offset < 0 {
+		return nil, errors.New("position out of range")
+	}
+	obj, node, err := objectNodeAt(tc.fset, unit, f, offset)
+	if err != nil {
+		// Not an identifier, nothing to show.
+		return nil, nil
 	}
-	tvParentStr := tvParent.Type.String()
 
-	if strings.Contains(tvStr, "func") {
-		if strings.Contains(tvParentStr, pkg.ImportPath) {
-			return hoverFuncDecl(ctx, reply, params, pkg, i)
+	var header, body string
+	if pn, ok := obj.(*types.PkgName); ok {
+		imported := pn.Imported()
+		header = fmt.Sprintf("package %s (%q)", imported.Name(), imported.Path())
+		if pkg := s.lookupPackage(ctx, snapshot, imported.Path()); pkg != nil {
+			body = strings.TrimSpace(pkg.Doc)
 		}
-
-		for _, spec := range pgf.File.Imports {
-			path := spec.Path.Value[1 : len(spec.Path.Value)-1]
-			if strings.Contains(tvParentStr, path) { // hover on parent var of kind import
-				parts := strings.Split(path, "/")
-				last := parts[len(parts)-1]
-				pkg := s.completionStore.lookupPkg(last)
-				if pkg == nil {
-					break
-				}
-				tvParentStrParts := strings.Split(tvParentStr, ".")
-				parentType := tvParentStrParts[len(tvParentStrParts)-1]
-				methods, ok := pkg.Methods.Get(parentType)
-				if !ok {
-					break
-				}
-				var header, body string
-				for _, m := range methods {
-					if m.Name == i.Name {
-						header = m.Signature
-						body = m.Doc
-					}
-				}
-				if header == "" {
-					break
-				}
-
-				return reply(ctx, protocol.Hover{
-					Contents: protocol.MarkupContent{
-						Kind:  protocol.Markdown,
-						Value: FormatHoverContent(header, body),
-					},
-					Range: posToRange(
-						int(params.Position.Line),
-						[]int{int(i.Pos()), int(i.End())},
-					),
-				}, nil)
+		body = joinParagraphs(body, packageLink(imported.Path()))
+	} else {
+		qf := func(p *types.Package) string {
+			if p == unit.pkg {
+				return ""
 			}
+			return p.Name()
 		}
-
-		// can be non gno.land import
-		// TODO: check if method has the same reciever
-		// TODO: better load the package and check Methods map
-		for _, spec := range pgf.File.Imports {
-			if strings.Contains(spec.Path.Value, "gno.land") {
-				continue
-			}
-			path := spec.Path.Value[1 : len(spec.Path.Value)-1]
-			symbol := s.completionStore.lookupSymbol(path, i.Name)
-			if symbol == nil {
-				continue
+		header = objectDecl(obj, unit.pkg, qf)
+		if obj.Pkg() == nil {
+			body = builtinDoc(obj.Name())
+		} else {
+			body = objectDoc(tc, obj)
+			if !isLocal(obj) && obj.Pkg().Path() != "" {
+				body = joinParagraphs(body, packageLink(obj.Pkg().Path()))
 			}
-			if symbol.Kind != "func" {
-				continue
-			}
-
-			return reply(ctx, protocol.Hover{
-				Contents: protocol.MarkupContent{
-					Kind:  protocol.Markdown,
-					Value: symbol.String(),
-				},
-				Range: posToRange(
-					int(params.Position.Line),
-					[]int{int(i.Pos()), int(i.End())},
-				),
-			}, nil)
 		}
-	} else {
-		t := tvStr
-		if strings.Contains(tvStr, pkg.ImportPath) {
-			t = strings.Replace(tvStr, pkg.ImportPath+".", "", 1)
-		}
-		header := fmt.Sprintf("%s %s %s", mode(*tv), i.Name, t)
-		return reply(ctx, protocol.Hover{
-			Contents: protocol.MarkupContent{
-				Kind:  protocol.Markdown,
-				Value: FormatHoverContent(header, ""),
-			},
-			Range: posToRange(
-				int(params.Position.Line),
-				[]int{int(i.Pos()), int(i.End())},
-			),
-		}, nil)
 	}
 
-	return reply(ctx, nil, nil)
-}
-
-func hoverMethodDecl(ctx context.Context, reply jsonrpc2.Replier, params protocol.HoverParams, pkg *Package, i *ast.Ident, decl *ast.FuncDecl) error {
-	if decl.Recv.NumFields() != 1 || decl.Recv.List[0].Type == nil {
-		return reply(ctx, nil, nil)
-	}
-
-	var key string
-	switch rt := decl.Recv.List[0].Type.(type) {
-	case *ast.StarExpr:
-		key = fmt.Sprintf("%s", rt.X)
-	case *ast.Ident:
-		key = fmt.Sprintf("%s", rt.Name)
-	default:
-		return reply(ctx, nil, nil)
-	}
-
-	methods, ok := pkg.Methods.Get(key)
-	if !ok {
-		return reply(ctx, nil, nil)
-	}
-
-	var header, body string
-	for _, m := range methods {
-		if m.Name == i.Name {
-			header = m.Signature
-			body = m.Doc
-			break
-		}
-	}
-
-	if header == "" {
-		return reply(ctx, nil, nil)
-	}
-
-	return reply(ctx, protocol.Hover{
+	rng := nodeToRange(tc.fset, node, tc.overlay)
+	return &protocol.Hover{
 		Contents: protocol.MarkupContent{
 			Kind:  protocol.Markdown,
 			Value: FormatHoverContent(header, body),
 		},
-		Range: posToRange(
-			int(params.Position.Line),
-			[]int{int(i.Pos()), int(i.End())},
-		),
-	}, nil)
+		Range: &rng,
+	}, nil
 }
 
-// TODO: handle var doc
-func hoverFuncDecl(ctx context.Context, reply jsonrpc2.Replier, params protocol.HoverParams, pkg *Package, i *ast.Ident) error {
-	var header, body string
-	for _, s := range pkg.Symbols {
-		if s.Name == i.Name {
-			header = s.Signature
-			body = s.Doc
-			break
+// objectDecl returns the declaration of obj, qualified by qf. Structs are
+// shown with their fields and interfaces with their methods, followed by the
+// methods of the type accessible from the package from.
+func objectDecl(obj types.Object, from *types.Package, qf types.Qualifier) string {
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		decl := typeDecl(obj, qf)
+		named, ok := obj.Type().(*types.Named)
+		if !ok || obj.IsAlias() || types.IsInterface(named) {
+			return decl
 		}
-	}
-
-	if header == "" {
-		return reply(ctx, nil, nil)
-	}
-
-	return reply(ctx, protocol.Hover{
-		Contents: protocol.MarkupContent{
-			Kind:  protocol.Markdown,
-			Value: FormatHoverContent(header, body),
-		},
-		Range: posToRange(
-			int(params.Position.Line),
-			[]int{int(i.Pos()), int(i.End())},
-		),
-	}, nil)
-}
-
-// TODO: handle var doc
-func hoverPackageLevelValue(ctx context.Context, reply jsonrpc2.Replier, params protocol.HoverParams, pkg *Package, i *ast.Ident, tv *types.TypeAndValue, mode, typeStr string, isPackageLevelGlobal bool) error {
-	var header, body string
-	for _, s := range pkg.Symbols {
-		if s.Name == i.Name {
-			header = s.Signature
-			body = s.Doc
-			break
+		var methods []string
+		for i := 0; i < named.NumMethods(); i++ {
+			if m := named.Method(i); m.Exported() || m.Pkg() == from {
+				methods = append(methods, funcDecl(m, qf))
+			}
 		}
+		if len(methods) > 0 {
+			decl += "\n\n" + strings.Join(methods, "\n")
+		}
+		return decl
+	case *types.Func:
+		return funcDecl(obj, qf)
+	case *types.Builtin:
+		if sig := builtinSignature(obj.Name()); sig != nil {
+			return "func " + sig.Label
+		}
+	case *types.Const:
+		return types.ObjectString(obj, qf) + " = " + obj.Val().String()
+	case *types.Nil:
+		return "var nil Type"
 	}
-
-	if header == "" {
-		return reply(ctx, nil, nil)
-	}
-
-	return reply(ctx, protocol.Hover{
-		Contents: protocol.MarkupContent{
-			Kind:  protocol.Markdown,
-			Value: FormatHoverContent(header, body),
-		},
-		Range: posToRange(
-			int(params.Position.Line),
-			[]int{int(i.Pos()), int(i.End())},
-		),
-	}, nil)
+	return types.ObjectString(obj, qf)
 }
 
-// TODO: handle var doc
-func hoverLocalVar(ctx context.Context, reply jsonrpc2.Replier, params protocol.HoverParams, pkg *Package, i *ast.Ident, tv *types.TypeAndValue, mode, typeStr string, isLocalGlobal bool) error {
-	t := typeStr
-	if isLocalGlobal {
-		t = strings.Replace(typeStr, pkg.ImportPath+".", "", 1)
+// typeDecl returns the declaration of the type tn, with its type parameters
+// and one line per field of structs and per method of interfaces.
+func typeDecl(tn *types.TypeName, qf types.Qualifier) string {
+	if tn.IsAlias() {
+		return fmt.Sprintf("type %s = %s", tn.Name(), types.TypeString(tn.Type(), qf))
 	}
-
-	header := fmt.Sprintf("%s %s %s", mode, i.Name, t)
-	return reply(ctx, protocol.Hover{
-		Contents: protocol.MarkupContent{
-			Kind:  protocol.Markdown,
-			Value: FormatHoverContent(header, ""),
-		},
-		Range: posToRange(
-			int(params.Position.Line),
-			[]int{int(i.Pos()), int(i.End())},
-		),
-	}, nil)
-}
-
-func hoverPackageLevelTypes(ctx context.Context, reply jsonrpc2.Replier, params protocol.HoverParams, pkg *Package, i *ast.Ident, tv *types.TypeAndValue, mode, typeName string) error {
-	// Look into structures
-	var structure *Structure
-	for _, st := range pkg.Structures {
-		if st.Name == fmt.Sprintf("%s", typeName) {
-			structure = st
-			break
+	var sb strings.Builder
+	sb.WriteString("type " + tn.Name())
+	if named, ok := tn.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+		tparams := named.TypeParams()
+		sb.WriteString("[")
+		for i := 0; i < tparams.Len(); i++ {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			tp := tparams.At(i)
+			sb.WriteString(tp.Obj().Name() + " " + types.TypeString(tp.Constraint(), qf))
 		}
+		sb.WriteString("]")
 	}
-	var header, body string
-	if structure != nil {
-		header = fmt.Sprintf("%s %s %s\n\n", mode, structure.Name, structure.String)
-		methods, ok := pkg.Methods.Get(typeName)
-		if ok {
-			body = "```gno\n"
-			for _, m := range methods {
-				if m.IsExported() {
-					body += fmt.Sprintf("%s\n", m.Signature)
-				}
-			}
-			body += "```\n"
-			body += structure.Doc + "\n"
+	sb.WriteString(" ")
+	switch u := tn.Type().Underlying().(type) {
+	case *types.Struct:
+		if u.NumFields() == 0 {
+			sb.WriteString("struct{}")
+			break
 		}
-	} else { // If not in structures, look into symbols
-		for _, s := range pkg.Symbols {
-			if s.Name == i.Name {
-				header = fmt.Sprintf("%s %s", mode, s.Signature)
-				body = s.Doc
-				break
+		sb.WriteString("struct {\n")
+		for i := 0; i < u.NumFields(); i++ {
+			f := u.Field(i)
+			sb.WriteString("\t")
+			if !f.Embedded() {
+				sb.WriteString(f.Name() + " ")
+			}
+			sb.WriteString(types.TypeString(f.Type(), qf))
+			if tag := u.Tag(i); tag != "" {
+				sb.WriteString(" `" + tag + "`")
 			}
+			sb.WriteString("\n")
 		}
+		sb.WriteString("}")
+	case *types.Interface:
+		if u.NumEmbeddeds() == 0 && u.NumExplicitMethods() == 0 {
+			sb.WriteString("interface{}")
+			break
+		}
+		sb.WriteString("interface {\n")
+		for i := 0; i < u.NumEmbeddeds(); i++ {
+			sb.WriteString("\t" + types.TypeString(u.EmbeddedType(i), qf) + "\n")
+		}
+		for i := 0; i < u.NumExplicitMethods(); i++ {
+			m := u.ExplicitMethod(i)
+			sb.WriteString("\t" + m.Name() + strings.TrimPrefix(types.TypeString(m.Type(), qf), "func") + "\n")
+		}
+		sb.WriteString("}")
+	default:
+		sb.WriteString(types.TypeString(u, qf))
 	}
-
-	if header == "" {
-		return reply(ctx, nil, nil)
-	}
-
-	return reply(ctx, protocol.Hover{
-		Contents: protocol.MarkupContent{
-			Kind:  protocol.Markdown,
-			Value: FormatHoverContent(header, body),
-		},
-		Range: posToRange(
-			int(params.Position.Line),
-			[]int{int(i.Pos()), int(i.End())},
-		),
-	}, nil)
-}
-
-func hoverBuiltinTypes(ctx context.Context, reply jsonrpc2.Replier, params protocol.HoverParams, i *ast.Ident, tv *types.TypeAndValue, mode, doc string) error {
-	t := tv.Type.String()
-	var header string
-	if t == "nil" || t == "untyped nil" { // special case?
-		header = "var nil Type"
-	} else if strings.HasPrefix(t, "func") && mode == "builtin" {
-		header = i.Name + strings.TrimPrefix(t, "func")
-	} else if (i.Name == "true" || i.Name == "false") && t == "bool" {
-		header = `const (
-	true	= 0 == 0	// Untyped bool.
-	false	= 0 != 0	// Untyped bool.
-)`
-	} else {
-		header = fmt.Sprintf("%s %s %s", mode, i.Name, t)
-	}
-
-	return reply(ctx, protocol.Hover{
-		Contents: protocol.MarkupContent{
-			Kind:  protocol.Markdown,
-			Value: FormatHoverContent(header, doc),
-		},
-		Range: posToRange(
-			int(params.Position.Line),
-			[]int{int(i.Pos()), int(i.End())},
-		),
-	}, nil)
+	return sb.String()
 }
 
-// TODO: check if imports exists in `examples` or `stdlibs`
-func hoverImport(ctx context.Context, reply jsonrpc2.Replier, pgf *ParsedGnoFile, params protocol.HoverParams, spec *ast.ImportSpec) error {
-	// remove leading and trailing `"`
-	path := spec.Path.Value[1 : len(spec.Path.Value)-1]
-	parts := strings.Split(path, "/")
-	last := parts[len(parts)-1]
-
-	header := fmt.Sprintf("package %s (%s)", last, spec.Path.Value)
-	body := func() string {
-		if strings.HasPrefix(path, "gno.land/") {
-			return fmt.Sprintf("[```%s``` on gno.land](https://%s)", last, path)
+// funcDecl returns the declaration of the function or method fn, like
+// `func (t *Tree) Size() int`.
+func funcDecl(fn *types.Func, qf types.Qualifier) string {
+	sig := fn.Type().(*types.Signature)
+	var recv string
+	if r := sig.Recv(); r != nil {
+		recv = "(" + types.TypeString(r.Type(), qf) + ") "
+		if r.Name() != "" && r.Name() != "_" {
+			recv = "(" + r.Name() + " " + types.TypeString(r.Type(), qf) + ") "
 		}
-		return fmt.Sprintf("[```%s``` on gno.land](https://gno.land)", last)
-	}()
-	return reply(ctx, protocol.Hover{
-		Contents: protocol.MarkupContent{
-			Kind:  protocol.Markdown,
-			Value: FormatHoverContent(header, body),
-		},
-		Range: posToRange(
-			int(params.Position.Line),
-			[]int{int(spec.Pos()), int(spec.End())},
-		),
-	}, nil)
+	}
+	return "func " + recv + fn.Name() + strings.TrimPrefix(types.TypeString(sig, qf), "func")
 }
 
-func hoverPackageIdent(ctx context.Context, reply jsonrpc2.Replier, pgf *ParsedGnoFile, params protocol.HoverParams, i *ast.Ident) error {
-	for _, spec := range pgf.File.Imports {
-		// remove leading and trailing `"`
-		path := spec.Path.Value[1 : len(spec.Path.Value)-1]
-		parts := strings.Split(path, "/")
-		last := parts[len(parts)-1]
-		if last == i.Name {
-			header := fmt.Sprintf("package %s (%s)", last, spec.Path.Value)
-			body := func() string {
-				if strings.HasPrefix(path, "gno.land/") {
-					return fmt.Sprintf("[```%s``` on gno.land](https://%s)", last, path)
-				}
-				return fmt.Sprintf("[```%s``` on gno.land](https://gno.land)", last)
-			}()
-			return reply(ctx, protocol.Hover{
-				Contents: protocol.MarkupContent{
-					Kind:  protocol.Markdown,
-					Value: FormatHoverContent(header, body),
-				},
-				Range: posToRange(
-					int(params.Position.Line),
-					[]int{int(i.Pos()), int(i.End())},
-				),
-			}, nil)
-		}
+// packageLink returns the import path of a package, linked to its source
+// for the packages of gno.land.
+func packageLink(path string) string {
+	if strings.HasPrefix(path, "gno.land/") {
+		return fmt.Sprintf("[`%s` on gno.land](https://%s)", path, path)
 	}
-	return reply(ctx, nil, nil)
+	return fmt.Sprintf("`%s`", path)
 }
 
-func hoverVariableIdent(ctx context.Context, reply jsonrpc2.Replier, pgf *ParsedGnoFile, params protocol.HoverParams, i *ast.Ident) error {
-	if i.Obj != nil {
-		switch u := i.Obj.Decl.(type) {
-		case *ast.Field:
-			if u.Type != nil {
-				switch t := u.Type.(type) {
-				case *ast.StarExpr:
-					header := fmt.Sprintf("%s %s *%s", i.Obj.Kind, u.Names[0], t.X)
-					return reply(ctx, protocol.Hover{
-						Contents: protocol.MarkupContent{
-							Kind:  protocol.Markdown,
-							Value: FormatHoverContent(header, ""),
-						},
-						Range: posToRange(
-							int(params.Position.Line),
-							[]int{int(i.Pos()), int(i.End())},
-						),
-					}, nil)
-				case *ast.Ident:
-					header := fmt.Sprintf("%s %s %s", i.Obj.Kind, u.Names[0], t.Name)
-					return reply(ctx, protocol.Hover{
-						Contents: protocol.MarkupContent{
-							Kind:  protocol.Markdown,
-							Value: FormatHoverContent(header, ""),
-						},
-						Range: posToRange(
-							int(params.Position.Line),
-							[]int{int(i.Pos()), int(i.End())},
-						),
-					}, nil)
-				}
-			}
-		case *ast.TypeSpec:
-			if u.Type != nil {
-				switch t := u.Type.(type) {
-				case *ast.StarExpr:
-					header := fmt.Sprintf("%s %s *%s", i.Obj.Kind, u.Name, t.X)
-					return reply(ctx, protocol.Hover{
-						Contents: protocol.MarkupContent{
-							Kind:  protocol.Markdown,
-							Value: FormatHoverContent(header, ""),
-						},
-						Range: posToRange(
-							int(params.Position.Line),
-							[]int{int(i.Pos()), int(i.End())},
-						),
-					}, nil)
-				case *ast.Ident:
-					header := fmt.Sprintf("%s %s %s", i.Obj.Kind, u.Name, t.Name)
-					return reply(ctx, protocol.Hover{
-						Contents: protocol.MarkupContent{
-							Kind:  protocol.Markdown,
-							Value: FormatHoverContent(header, ""),
-						},
-						Range: posToRange(
-							int(params.Position.Line),
-							[]int{int(i.Pos()), int(i.End())},
-						),
-					}, nil)
-				}
-			}
-		case *ast.ValueSpec:
-			if u.Type != nil {
-				switch t := u.Type.(type) {
-				case *ast.StarExpr:
-					header := fmt.Sprintf("%s %s *%s", i.Obj.Kind, u.Names[0], t.X)
-					return reply(ctx, protocol.Hover{
-						Contents: protocol.MarkupContent{
-							Kind:  protocol.Markdown,
-							Value: FormatHoverContent(header, ""),
-						},
-						Range: posToRange(
-							int(params.Position.Line),
-							[]int{int(i.Pos()), int(i.End())},
-						),
-					}, nil)
-				case *ast.Ident:
-					header := fmt.Sprintf("%s %s %s", i.Obj.Kind, u.Names[0], t.Name)
-					return reply(ctx, protocol.Hover{
-						Contents: protocol.MarkupContent{
-							Kind:  protocol.Markdown,
-							Value: FormatHoverContent(header, ""),
-						},
-						Range: posToRange(
-							int(params.Position.Line),
-							[]int{int(i.Pos()), int(i.End())},
-						),
-					}, nil)
-				}
-			}
-		default:
-			slog.Info("hover", "NOT HANDLED", u)
+// joinParagraphs joins the non-empty markdown paragraphs.
+func joinParagraphs(paragraphs ...string) string {
+	var res []string
+	for _, p := range paragraphs {
+		if p != "" {
+			res = append(res, p)
 		}
 	}
-	return reply(ctx, nil, nil)
+	return strings.Join(res, "\n\n")
 }
 
 func FormatHoverContent(header, body string) string {
 	return fmt.Sprintf("```gno\n%s\n```\n\n%s", header, body)
 }
 
-// getIdentNodes return idents from Expr
-// Note: only handles *ast.SelectorExpr and  *ast.CallExpr
-func getIdentNodes(n ast.Node) []*ast.Ident {
-	res := []*ast.Ident{}
-	switch t := n.(type) {
-	case *ast.Ident:
-		res = append(res, t)
-	case *ast.SelectorExpr:
-		res = append(res, t.Sel)
-		res = append(res, getIdentNodes(t.X)...)
-	case *ast.CallExpr:
-		res = append(res, getIdentNodes(t.Fun)...)
-	}
-
-	return res
-}
-
-func getExprAtLine(pgf *ParsedGnoFile, line int) ast.Expr {
-	var expr ast.Expr
-	ast.Inspect(pgf.File, func(n ast.Node) bool {
-		if e, ok := n.(ast.Expr); ok && pgf.Fset.Position(e.Pos()).Line == int(line) {
-			expr = e
-			return false
-		}
-		return true
-	})
-	return expr
-}
-
 // pathEnclosingObjNode returns the AST path to the object-defining
 // node associated with pos. "Object-defining" means either an
 // *ast.Ident mapped directly to a types.Object or an ast.Node mapped
@@ -758,9 +287,3 @@ func pathEnclosingObjNode(f *ast.File, pos token.Pos) []ast.Node {
 
 	return path
 }
-
-// parseType parses the type name from full path and return
-// the type as string and if it is isStar expr.
-func parseType(t, importpath string) string {
-	return strings.TrimPrefix(strings.TrimPrefix(t, "*"), importpath+".")
-}
@@ -0,0 +1,362 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go/types"
+	"maps"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+func (s *server) TypeDefinition(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.TypeDefinitionParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	locs, err := s.typeDefinition(ctx, snapshot, file, params.Position)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, locs, nil)
+}
+
+// typeDefinition returns the locations of the named types of the object
+// denoted by the identifier at pos in file. Pointers and composite types
+// lead to the named types of their elements, and functions to the ones of
+// their results.
+func (s *server) typeDefinition(ctx context.Context, snapshot *Snapshot, file *GnoFile, pos protocol.Position) ([]protocol.Location, error) {
+	tc, unit, _, err := s.checkFile(ctx, snapshot, file)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := objectAt(tc.fset, []*TypeCheckResult{unit}, file.URI.Filename(), file.PositionToOffset(pos))
+	if err != nil {
+		return nil, nil // no identifier at pos
+	}
+	if _, ok := obj.(*types.PkgName); ok {
+		return nil, nil
+	}
+
+	locs := []protocol.Location{}
+	for _, named := range namedTypes(obj.Type()) {
+		if loc := objectLocation(tc.fset, named.Obj(), tc.overlay); loc != nil {
+			locs = append(locs, *loc)
+		}
+	}
+	return locs, nil
+}
+
+// namedTypes returns the named types found in t, e.g. the key and the value
+// of a map.
+func namedTypes(t types.Type) []*types.Named {
+	var res []*types.Named
+	seen := map[*types.Named]bool{}
+	var walk func(t types.Type)
+	walk = func(t types.Type) {
+		switch t := t.(type) {
+		case *types.Named:
+			if !seen[t] {
+				seen[t] = true
+				res = append(res, t)
+			}
+		case *types.Pointer:
+			walk(t.Elem())
+		case *types.Slice:
+			walk(t.Elem())
+		case *types.Array:
+			walk(t.Elem())
+		case *types.Map:
+			walk(t.Key())
+			walk(t.Elem())
+		case *types.Chan:
+			walk(t.Elem())
+		case *types.Signature:
+			for i := 0; i < t.Results().Len(); i++ {
+				walk(t.Results().At(i).Type())
+			}
+		}
+	}
+	walk(t)
+	return res
+}
+
+func (s *server) Implementation(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.ImplementationParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	locs, err := s.implementation(ctx, snapshot, file, params.Position)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, locs, nil)
+}
+
+// implementation returns the locations of the concrete types implementing
+// the interface denoted by the identifier at pos in file, or of the
+// interfaces implemented by the concrete type. For methods, the locations
+// are the ones of the corresponding methods. The types are searched in the
+// workspace and in the gno.land packages of GNOROOT and GNOHOME.
+func (s *server) implementation(ctx context.Context, snapshot *Snapshot, file *GnoFile, pos protocol.Position) ([]protocol.Location, error) {
+	s.typeIndexMu.Lock()
+	defer s.typeIndexMu.Unlock()
+	index := s.typeIndexOf(ctx, snapshot)
+	if index == nil {
+		return nil, ctx.Err()
+	}
+	tc, unit, err := index.checkFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := objectAt(tc.fset, []*TypeCheckResult{unit}, file.URI.Filename(), file.PositionToOffset(pos))
+	if err != nil {
+		return nil, nil // no identifier at pos
+	}
+
+	var t types.Type
+	var method *types.Func
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		t = obj.Type()
+	case *types.Func:
+		recv := obj.Type().(*types.Signature).Recv()
+		if recv == nil {
+			return nil, nil
+		}
+		t, method = recv.Type(), obj
+	case *types.Var:
+		t = obj.Type()
+	default:
+		return nil, nil
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if _, ok := t.(*types.Named); !ok {
+		return nil, nil
+	}
+
+	locs := []protocol.Location{}
+	for _, tn := range index.typesWith(unit.pkg) {
+		candidate := tn.Type()
+		if types.Identical(candidate, t) || !implements(t, candidate) {
+			continue
+		}
+		target := types.Object(tn)
+		if method != nil {
+			target, _, _ = types.LookupFieldOrMethod(candidate, true, method.Pkg(), method.Name())
+			if target == nil {
+				continue
+			}
+		}
+		if loc := objectLocation(tc.fset, target, tc.overlay); loc != nil {
+			locs = append(locs, *loc)
+		}
+	}
+	return locs, nil
+}
+
+// implements reports whether t and candidate are an interface and a
+// concrete type, in any order, and the concrete type, or a pointer to it,
+// implements the interface. Empty interfaces are ignored, as all types
+// implement them.
+func implements(t, candidate types.Type) bool {
+	iface, concrete := t, candidate
+	if !types.IsInterface(iface) {
+		iface, concrete = concrete, iface
+	}
+	if !types.IsInterface(iface) || types.IsInterface(concrete) {
+		return false
+	}
+	it := iface.Underlying().(*types.Interface)
+	if it.NumMethods() == 0 {
+		return false
+	}
+	return types.Implements(concrete, it) || types.Implements(types.NewPointer(concrete), it)
+}
+
+// typeIndex holds the package-level named types of the workspace packages
+// and of the gno.land packages of the completion store. It's updated for
+// the snapshot of each request, the packages whose files changed, and their
+// importers, are type-checked again.
+type typeIndex struct {
+	// files are the files of the snapshot the index is up to date with.
+	files map[string]*GnoFile
+	// tc type-checked the indexed packages.
+	tc *TypeCheck
+	// types holds the named types by import path.
+	types map[string][]*types.TypeName
+}
+
+// typeIndexOf returns the type index updated for snapshot. nil is returned
+// if ctx is cancelled while updating it. typeIndexMu must be held while the
+// index is used.
+func (s *server) typeIndexOf(ctx context.Context, snapshot *Snapshot) *typeIndex {
+	index := s.typeIndex
+	if index == nil {
+		tc, _ := s.newTypeCheck(snapshot)
+		// The errors aren't reported, they would pile up as tc lives on.
+		tc.cfg.Error = func(error) {}
+		index = &typeIndex{files: snapshot.files, tc: tc, types: map[string][]*types.TypeName{}}
+		s.typeIndex = index
+	} else {
+		index.invalidate(snapshot)
+	}
+	index.tc.overlay = snapshot.Overlay()
+	index.tc.dirs = maps.Clone(s.workspacePackageDirs())
+
+	var paths []string
+	for path := range s.workspacePackageDirs() {
+		paths = append(paths, path)
+	}
+	for _, pkg := range s.completionStore.pkgs {
+		// The stdlibs are left out.
+		if strings.HasPrefix(pkg.ImportPath, "gno.land/") {
+			paths = append(paths, pkg.ImportPath)
+		}
+	}
+	indexed := make(map[string][]*types.TypeName, len(paths))
+	for _, path := range paths {
+		if named, ok := index.types[path]; ok {
+			indexed[path] = named
+			continue
+		}
+		pkg, err := index.tc.Import(ctx, path)
+		if ctx.Err() != nil {
+			// The packages imported so far are kept for the next request.
+			return nil
+		}
+		if err != nil {
+			continue
+		}
+		indexed[path] = namedTypesOf(pkg)
+	}
+	index.types = indexed
+	return index
+}
+
+// invalidate drops the packages located in the directories whose files
+// differ between snapshot and the one of index, along with the packages
+// importing them.
+func (index *typeIndex) invalidate(snapshot *Snapshot) {
+	changed := map[string]bool{}
+	for name, f := range snapshot.files {
+		if index.files[name] != f {
+			changed[filepath.Dir(name)] = true
+		}
+	}
+	for name := range index.files {
+		if _, ok := snapshot.files[name]; !ok {
+			changed[filepath.Dir(name)] = true
+		}
+	}
+	index.files = snapshot.files
+	if len(changed) == 0 {
+		return
+	}
+
+	tc := index.tc
+	stale := map[string]bool{}
+	for path, res := range tc.cache {
+		// The packages not found may have been created meanwhile.
+		if res.pkg == nil || len(res.files) == 0 || changed[filepath.Dir(tc.fset.File(res.files[0].Pos()).Name())] {
+			stale[path] = true
+		}
+	}
+	for found := len(stale) > 0; found; {
+		found = false
+		for path, res := range tc.cache {
+			if stale[path] {
+				continue
+			}
+			for _, imp := range res.pkg.Imports() {
+				if stale[imp.Path()] {
+					stale[path] = true
+					found = true
+					break
+				}
+			}
+		}
+	}
+
+	tc.files = nil
+	for path, res := range tc.cache {
+		if stale[path] {
+			delete(tc.cache, path)
+			delete(index.types, path)
+			continue
+		}
+		tc.files = append(tc.files, res.files...)
+	}
+}
+
+// checkFile returns the type-check result of the package of file, whose
+// types are identical to the indexed ones, and the TypeCheck which checked
+// it. The indexed package is used if it holds file, otherwise the package
+// is type-checked by a fork of the TypeCheck of the index.
+func (index *typeIndex) checkFile(ctx context.Context, file *GnoFile) (*TypeCheck, *TypeCheckResult, error) {
+	filename := file.URI.Filename()
+	if res, ok := index.tc.cache[importPathFromGnoMod(filepath.Dir(filename))]; ok && res.pkg != nil {
+		if unit, f := unitOf(index.tc.fset, []*TypeCheckResult{res}, filename); f != nil {
+			return index.tc, unit, nil
+		}
+	}
+	tc := index.tc.fork()
+	unit, _, err := checkFileWith(ctx, tc, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tc, unit, nil
+}
+
+// typesWith returns the types of pkg followed by the indexed ones, sorted by
+// import path. The indexed types of the import path of pkg are left out.
+func (index *typeIndex) typesWith(pkg *types.Package) []*types.TypeName {
+	paths := make([]string, 0, len(index.types))
+	for path := range index.types {
+		if path != pkg.Path() {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	res := namedTypesOf(pkg)
+	for _, path := range paths {
+		res = append(res, index.types[path]...)
+	}
+	return res
+}
+
+// namedTypesOf returns the package-level named types of pkg, aliases left
+// out.
+func namedTypesOf(pkg *types.Package) []*types.TypeName {
+	var res []*types.TypeName
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if tn, ok := scope.Lookup(name).(*types.TypeName); ok && !tn.IsAlias() {
+			res = append(res, tn)
+		}
+	}
+	return res
+}
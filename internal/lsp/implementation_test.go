@@ -0,0 +1,60 @@
+package lsp
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestImplementation(t *testing.T) {
+	s, dir := newTestServer(t, map[string]string{
+		"store/gno.mod":   "module gno.land/p/test/store\n",
+		"store/store.gno": "package store\n\ntype Key string\n\ntype Storer interface {\n\tStore(k Key)\n}\n",
+		"impl/gno.mod":    "module gno.land/p/test/impl\n",
+		"impl/impl.gno":   "package impl\n\nimport \"gno.land/p/test/store\"\n\ntype Mem struct{}\n\nfunc (m *Mem) Store(k store.Key) {}\n",
+	})
+	implURI := getURI(filepath.Join(dir, "impl", "impl.gno"))
+	mem := protocol.Location{
+		URI:   implURI,
+		Range: protocol.Range{Start: protocol.Position{Line: 4, Character: 5}, End: protocol.Position{Line: 4, Character: 8}},
+	}
+	memStore := protocol.Location{
+		URI:   implURI,
+		Range: protocol.Range{Start: protocol.Position{Line: 6, Character: 14}, End: protocol.Position{Line: 6, Character: 19}},
+	}
+	tests := []struct {
+		name string
+		src  string // content of store.gno, unchanged if empty
+		pos  protocol.Position
+		want []protocol.Location
+	}{
+		{"interface", "", protocol.Position{Line: 4, Character: 6}, []protocol.Location{mem}},
+		{"method", "", protocol.Position{Line: 5, Character: 1}, []protocol.Location{memStore}},
+		// The importers of the edited package are checked again.
+		{"edited", "package store\n\ntype Key string\n\n// Storer stores keys.\ntype Storer interface {\n\tStore(k Key)\n}\n", protocol.Position{Line: 5, Character: 6}, []protocol.Location{mem}},
+		{"not implemented", "package store\n\ntype Key string\n\ntype Storer interface {\n\tStore(k Key)\n\tLen() int\n}\n", protocol.Position{Line: 4, Character: 6}, []protocol.Location{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := testFile(t, s, dir, filepath.Join("store", "store.gno"))
+			if tt.src != "" {
+				file = &GnoFile{URI: file.URI, Src: []byte(tt.src), Version: file.Version + 1}
+				s.snapshot = s.snapshot.WithFile(file)
+			}
+			got, err := s.implementation(context.Background(), s.getSnapshot(), file, tt.pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
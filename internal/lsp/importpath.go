@@ -0,0 +1,108 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/doc"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// importSpecAt returns the import spec of pgf whose path literal contains
+// offset, after its opening quote, or nil.
+func importSpecAt(pgf *ParsedGnoFile, offset int) *ast.ImportSpec {
+	for _, spec := range pgf.File.Imports {
+		start := pgf.Fset.Position(spec.Path.Pos()).Offset
+		end := pgf.Fset.Position(spec.Path.End()).Offset
+		if start < offset && offset <= end {
+			return spec
+		}
+	}
+	return nil
+}
+
+// importPathCompletion returns the completion of the import path being
+// written in spec, up to offset of file. The path is completed one segment
+// at a time, e.g. `gno.land/p/de` is completed with `demo` and the other
+// packages and directories of `gno.land/p/`.
+func (s *server) importPathCompletion(snapshot *Snapshot, file *GnoFile, pgf *ParsedGnoFile, spec *ast.ImportSpec, offset int) *protocol.CompletionList {
+	start := pgf.Fset.Position(spec.Path.Pos()).Offset + 1 // after the quote
+	typed := string(file.Src[start:offset])
+	dir := typed[:strings.LastIndex(typed, "/")+1]
+	tokFile := pgf.Fset.File(spec.Pos())
+	rng := tokenRange(pgf.Fset, tokFile.Pos(start+len(dir)), tokFile.Pos(offset), pgf.overlay())
+
+	self := importPathFromGnoMod(filepath.Dir(pgf.URI.Filename()))
+	docs := map[string]string{} // package docs by import path
+	var paths []string
+	for _, pkg := range append(s.workspacePackages(snapshot), s.completionStore.pkgs...) {
+		if _, ok := docs[pkg.ImportPath]; ok || pkg.ImportPath == self || !strings.HasPrefix(pkg.ImportPath, dir) {
+			continue
+		}
+		if !importable(pkg.ImportPath, self) {
+			continue
+		}
+		docs[pkg.ImportPath] = pkg.Doc
+		paths = append(paths, pkg.ImportPath)
+	}
+	sort.Strings(paths)
+
+	items := []protocol.CompletionItem{}
+	seen := map[string]int{} // index of the item of each segment
+	for _, path := range paths {
+		segment, _, isDir := strings.Cut(path[len(dir):], "/")
+		if i, ok := seen[segment]; ok {
+			if !isDir && items[i].Kind == protocol.CompletionItemKindFolder {
+				// Both a package and a directory, prefer the package.
+				items[i] = importPathItem(path, segment, docs[path], rng)
+			}
+			continue
+		}
+		seen[segment] = len(items)
+		if isDir {
+			items = append(items, protocol.CompletionItem{
+				Label:    segment,
+				Kind:     protocol.CompletionItemKindFolder,
+				Detail:   dir + segment + "/",
+				TextEdit: &protocol.TextEdit{Range: rng, NewText: segment + "/"},
+			})
+			continue
+		}
+		items = append(items, importPathItem(path, segment, docs[path], rng))
+	}
+
+	candidates := make([]candidate, 0, len(items))
+	for _, item := range items {
+		candidates = append(candidates, candidate{item: item, score: 1})
+	}
+	return s.completionList(candidates, typed[len(dir):])
+}
+
+// importable reports whether the package of the given path can be imported
+// by the package self: internal packages are only importable from the tree
+// rooted at their parent.
+func importable(path, self string) bool {
+	i := strings.LastIndex("/"+path+"/", "/internal/")
+	if i < 0 {
+		return true
+	}
+	parent := path[:max(i-1, 0)]
+	return parent != "" && (self == parent || strings.HasPrefix(self, parent+"/"))
+}
+
+// importPathItem returns the completion item of the package of the given
+// import path, replacing rng by its last segment.
+func importPathItem(path, segment, pkgDoc string, rng protocol.Range) protocol.CompletionItem {
+	item := protocol.CompletionItem{
+		Label:    segment,
+		Kind:     protocol.CompletionItemKindModule,
+		Detail:   path,
+		TextEdit: &protocol.TextEdit{Range: rng, NewText: segment},
+	}
+	if synopsis := new(doc.Package).Synopsis(pkgDoc); synopsis != "" {
+		item.Documentation = synopsis
+	}
+	return item
+}
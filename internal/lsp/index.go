@@ -0,0 +1,115 @@
+package lsp
+
+import (
+	"sort"
+
+	"github.com/gnolang/gnopls/internal/fuzzy"
+	"go.lsp.dev/protocol"
+)
+
+// SymbolIndex is a searchable index of the package level declarations
+// (functions, methods, types, constants and variables) of a set of packages.
+type SymbolIndex struct {
+	entries []*symbolEntry
+}
+
+type symbolEntry struct {
+	name      string // e.g. `Tree`, or `Tree.Get` for methods
+	qualified string // e.g. `avl.Tree`
+	pkgPath   string // e.g. `gno.land/p/demo/avl`
+	kind      protocol.SymbolKind
+	location  protocol.Location
+}
+
+// NewSymbolIndex indexes the declarations of pkgs, whose files are read
+// from overlay if open. The location of a declaration is the one of its
+// name.
+func NewSymbolIndex(pkgs []*Package, overlay map[string][]byte) *SymbolIndex {
+	idx := &SymbolIndex{}
+	add := func(pkg *Package, name, kind string, s *Symbol) {
+		start := lspPosition(s.Position, overlay)
+		end := start
+		end.Character += utf16Len([]byte(s.Name))
+		idx.entries = append(idx.entries, &symbolEntry{
+			name:      name,
+			qualified: pkg.Name + "." + name,
+			pkgPath:   pkg.ImportPath,
+			kind:      symbolToSymbolKind(kind),
+			location: protocol.Location{
+				URI: s.FileURI,
+				Range: protocol.Range{
+					Start: start,
+					End:   end,
+				},
+			},
+		})
+	}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Functions {
+			add(pkg, f.Name, "func", &Symbol{Position: f.Position, FileURI: f.FileURI, Name: f.Name})
+		}
+		for recv, methods := range pkg.Methods.Items() {
+			for _, m := range methods {
+				add(pkg, recv+"."+m.Name, "method", &Symbol{Position: m.Position, FileURI: m.FileURI, Name: m.Name})
+			}
+		}
+		for _, s := range pkg.Symbols {
+			if s.Kind == "func" { // already indexed as function or method
+				continue
+			}
+			add(pkg, s.Name, s.Kind, s)
+		}
+	}
+	return idx
+}
+
+type symbolMatch struct {
+	entry *symbolEntry
+	score float64
+}
+
+// searchSymbols returns the symbols of the indexes matching the query,
+// best matches first. The result is truncated to limit items.
+func searchSymbols(query string, limit int, indexes ...*SymbolIndex) []protocol.SymbolInformation {
+	matcher := fuzzy.NewMatcher(query)
+	seen := map[protocol.Location]bool{}
+	var matches []symbolMatch
+	for _, idx := range indexes {
+		for _, e := range idx.entries {
+			if seen[e.location] {
+				continue
+			}
+			score := max(matcher.Score(e.name), matcher.Score(e.qualified))
+			if score == 0 {
+				continue
+			}
+			seen[e.location] = true
+			matches = append(matches, symbolMatch{entry: e, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		mi, mj := matches[i], matches[j]
+		if mi.score != mj.score {
+			return mi.score > mj.score
+		}
+		if len(mi.entry.name) != len(mj.entry.name) {
+			return len(mi.entry.name) < len(mj.entry.name)
+		}
+		return mi.entry.pkgPath+"."+mi.entry.name < mj.entry.pkgPath+"."+mj.entry.name
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	res := make([]protocol.SymbolInformation, 0, len(matches))
+	for _, m := range matches {
+		res = append(res, protocol.SymbolInformation{
+			Name:          m.entry.pkgPath + "." + m.entry.name,
+			Kind:          m.entry.kind,
+			Location:      m.entry.location,
+			ContainerName: m.entry.pkgPath,
+		})
+	}
+	return res
+}
@@ -0,0 +1,256 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+// inlayHintParams is the LSP InlayHintParams, missing from protocol.
+type inlayHintParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	Range        protocol.Range                  `json:"range"`
+}
+
+// inlayHintKind is the LSP InlayHintKind.
+type inlayHintKind int
+
+const (
+	inlayHintKindType      inlayHintKind = 1
+	inlayHintKindParameter inlayHintKind = 2
+)
+
+// inlayHint is the LSP InlayHint, with a label made of a single string.
+type inlayHint struct {
+	Position     protocol.Position `json:"position"`
+	Label        string            `json:"label"`
+	Kind         inlayHintKind     `json:"kind,omitempty"`
+	PaddingLeft  bool              `json:"paddingLeft,omitempty"`
+	PaddingRight bool              `json:"paddingRight,omitempty"`
+}
+
+// inlayHintSettings enables the categories of inlay hints, all disabled by
+// default.
+type inlayHintSettings struct {
+	// ParameterNames shows the names of the parameters at call sites.
+	ParameterNames bool `json:"parameterNames"`
+	// AssignVariableTypes shows the types of the variables declared by :=.
+	AssignVariableTypes bool `json:"assignVariableTypes"`
+	// RangeVariableTypes shows the types of the variables declared by range
+	// statements.
+	RangeVariableTypes bool `json:"rangeVariableTypes"`
+	// ConstantValues shows the values of the constants declared with iota.
+	ConstantValues bool `json:"constantValues"`
+	// FunctionTypeParameters shows the inferred type arguments of the calls
+	// of generic functions.
+	FunctionTypeParameters bool `json:"functionTypeParameters"`
+}
+
+func (s *server) InlayHint(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params inlayHintParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	hints, err := s.inlayHints(ctx, snapshot, file, params.Range)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, hints, nil)
+}
+
+// inlayHints returns the hints of the enabled categories in rng of file.
+func (s *server) inlayHints(ctx context.Context, snapshot *Snapshot, file *GnoFile, rng protocol.Range) ([]inlayHint, error) {
+	cfg := s.settings.InlayHints
+	hints := []inlayHint{}
+	if cfg == (inlayHintSettings{}) {
+		return hints, nil
+	}
+	tc, unit, f, err := s.checkFile(ctx, snapshot, file)
+	if err != nil {
+		return nil, err
+	}
+	info := unit.info
+	qf := func(p *types.Package) string {
+		if p == unit.pkg {
+			return ""
+		}
+		return p.Name()
+	}
+	add := func(pos token.Pos, label string, kind inlayHintKind) {
+		position := lspPosition(tc.fset.Position(pos), tc.overlay)
+		if !inRange(rng, position) {
+			return
+		}
+		hint := inlayHint{
+			Position: position,
+			Label:    label,
+			Kind:     kind,
+		}
+		if kind == inlayHintKindParameter {
+			hint.PaddingRight = true
+		} else {
+			hint.PaddingLeft = true
+		}
+		hints = append(hints, hint)
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if cfg.ParameterNames {
+				parameterNameHints(info, n, add)
+			}
+			if cfg.FunctionTypeParameters {
+				typeArgumentHints(info, n, qf, add)
+			}
+		case *ast.AssignStmt:
+			if cfg.AssignVariableTypes && n.Tok == token.DEFINE {
+				for _, lhs := range n.Lhs {
+					variableTypeHint(info, lhs, qf, add)
+				}
+			}
+		case *ast.RangeStmt:
+			if cfg.RangeVariableTypes && n.Tok == token.DEFINE {
+				variableTypeHint(info, n.Key, qf, add)
+				variableTypeHint(info, n.Value, qf, add)
+			}
+		case *ast.GenDecl:
+			if cfg.ConstantValues && n.Tok == token.CONST {
+				constantValueHints(info, n, add)
+			}
+		}
+		return true
+	})
+	slices.SortStableFunc(hints, func(a, b inlayHint) int {
+		if a.Position.Line != b.Position.Line {
+			return int(a.Position.Line) - int(b.Position.Line)
+		}
+		return int(a.Position.Character) - int(b.Position.Character)
+	})
+	return hints, nil
+}
+
+// parameterNameHints adds the names of the parameters before the arguments
+// of call, except the ones already named like their parameter.
+func parameterNameHints(info *types.Info, call *ast.CallExpr, add func(token.Pos, string, inlayHintKind)) {
+	tv, ok := info.Types[call.Fun]
+	if !ok || tv.IsType() || tv.IsBuiltin() {
+		return
+	}
+	sig, ok := tv.Type.Underlying().(*types.Signature)
+	if !ok {
+		return
+	}
+	params := sig.Params()
+	for i, arg := range call.Args {
+		j := i
+		if sig.Variadic() && j >= params.Len()-1 {
+			j = params.Len() - 1
+			if i > j {
+				break // only the first variadic argument is named
+			}
+		}
+		if j >= params.Len() {
+			break
+		}
+		name := params.At(j).Name()
+		if name == "" || name == "_" {
+			continue
+		}
+		if id, ok := arg.(*ast.Ident); ok && id.Name == name {
+			continue
+		}
+		if sig.Variadic() && j == params.Len()-1 {
+			name = "..." + name
+		}
+		add(arg.Pos(), name+":", inlayHintKindParameter)
+	}
+}
+
+// typeArgumentHints adds the type arguments inferred for the generic
+// function called by call, after its name.
+func typeArgumentHints(info *types.Info, call *ast.CallExpr, qf types.Qualifier, add func(token.Pos, string, inlayHintKind)) {
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return // explicit type arguments
+	}
+	inst, ok := info.Instances[id]
+	if !ok || inst.TypeArgs.Len() == 0 {
+		return
+	}
+	args := make([]string, inst.TypeArgs.Len())
+	for i := range args {
+		args[i] = types.TypeString(inst.TypeArgs.At(i), qf)
+	}
+	add(id.End(), "["+strings.Join(args, ", ")+"]", inlayHintKindType)
+}
+
+// variableTypeHint adds the type of the variable declared by expr after
+// it, if any.
+func variableTypeHint(info *types.Info, expr ast.Expr, qf types.Qualifier, add func(token.Pos, string, inlayHintKind)) {
+	id, ok := expr.(*ast.Ident)
+	if !ok || id.Name == "_" {
+		return
+	}
+	// Variables redeclared by := have no definition.
+	obj, ok := info.Defs[id].(*types.Var)
+	if !ok {
+		return
+	}
+	add(id.End(), types.TypeString(obj.Type(), qf), inlayHintKindType)
+}
+
+// constantValueHints adds the values of the constants of decl after their
+// specs, when they are implied or computed from iota.
+func constantValueHints(info *types.Info, decl *ast.GenDecl, add func(token.Pos, string, inlayHintKind)) {
+	for _, spec := range decl.Specs {
+		vs, ok := spec.(*ast.ValueSpec)
+		if !ok || (len(vs.Values) > 0 && !usesIota(info, vs.Values)) {
+			continue
+		}
+		var values []string
+		for _, name := range vs.Names {
+			if c, ok := info.Defs[name].(*types.Const); ok {
+				values = append(values, c.Val().ExactString())
+			}
+		}
+		if len(values) > 0 {
+			add(vs.End(), "= "+strings.Join(values, ", "), 0)
+		}
+	}
+}
+
+// usesIota reports whether one of exprs refers to iota.
+func usesIota(info *types.Info, exprs []ast.Expr) bool {
+	found := false
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && info.Uses[id] == types.Universe.Lookup("iota") {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
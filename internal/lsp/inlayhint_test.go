@@ -0,0 +1,32 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestInlayHintsUTF16(t *testing.T) {
+	s, dir := newTestServer(t, map[string]string{
+		"gno.mod": "module gno.land/r/test/ih\n",
+		"ih.gno":  "package ih\n\nfunc g(n int) int { return n }\n\nvar é = \"😀\"; var x = g(1)\n",
+	})
+	s.settings.InlayHints.ParameterNames = true
+	file := testFile(t, s, dir, "ih.gno")
+	rng := protocol.Range{End: protocol.Position{Line: 5}}
+	hints, err := s.inlayHints(context.Background(), s.getSnapshot(), file, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 is at character 24, after the surrogate pair of 😀.
+	want := inlayHint{
+		Position:     protocol.Position{Line: 4, Character: 24},
+		Label:        "n:",
+		Kind:         inlayHintKindParameter,
+		PaddingRight: true,
+	}
+	if len(hints) != 1 || hints[0] != want {
+		t.Errorf("got hints %v, want %v", hints, want)
+	}
+}
@@ -0,0 +1,383 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	gno "github.com/gnolang/gno/gnovm/pkg/gnolang"
+	"github.com/gnolang/gno/gnovm/stdlibs"
+	dbm "github.com/gnolang/gno/tm2/pkg/db"
+	"github.com/gnolang/gno/tm2/pkg/store/dbadapter"
+	"github.com/gnolang/gno/tm2/pkg/store/iavl"
+	stypes "github.com/gnolang/gno/tm2/pkg/store/types"
+	"go.lsp.dev/protocol"
+)
+
+// preprocessMu serializes the runs of the gno preprocessor, which relies on
+// global state, and guards lastPreprocessStore.
+var preprocessMu sync.Mutex
+
+// lastPreprocessStore is the store of the last run of the preprocessor. Its
+// imported packages are reused by the next run, see preprocessStoreFor.
+var lastPreprocessStore *preprocessStore
+
+// preprocessErrorRe matches the locations prepended to the errors of the gno
+// preprocessor, e.g. `gno.land/r/demo/foo/foo.gno:12: `, followed by the
+// column in the newer versions of gno.
+var preprocessErrorRe = regexp.MustCompile(`(\S+\.gno):(\d+)(?::(\d+))?(?:#\d+)?: `)
+
+// checkGnoPackage reports the constructs of the package located in dir
+// which are accepted by the go type-checker but not by the gno VM. The files
+// are converted by gno first, and if they all are, they are preprocessed
+// when preprocess is true. The test files are left to the gno test runner,
+// and the imports are resolved like tc, built for snapshot, does until ctx
+// is cancelled.
+func checkGnoPackage(ctx context.Context, snapshot *Snapshot, tc *TypeCheck, dir, pkgPath string, preprocess bool) (errs []ErrorInfo) {
+	filenames, err := listGnoFiles(dir, tc.overlay)
+	if err != nil {
+		return nil
+	}
+	var files []*gno.FileNode
+	for _, fname := range filenames {
+		if strings.HasSuffix(fname, "_test.gno") || strings.HasSuffix(fname, "_filetest.gno") {
+			continue
+		}
+		src, err := readFile(fname, tc.overlay)
+		if err != nil {
+			return nil
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, fname, src, parser.ParseComments|parser.DeclarationErrors)
+		if err != nil {
+			// Syntax errors are reported by the type-check.
+			preprocess = false
+			continue
+		}
+		fn, err := go2gno(fset, f)
+		if err != nil {
+			preprocess = false
+			errs = append(errs, convertError(err, fset, f, tc.overlay))
+			continue
+		}
+		fn.Name = gno.Name(filepath.Base(fname))
+		setDeclLines(fset, f, fn)
+		files = append(files, fn)
+	}
+	if !preprocess || len(files) == 0 {
+		return errs
+	}
+	if pkgPath == "" {
+		pkgPath = "main"
+	}
+
+	preprocessMu.Lock()
+	defer preprocessMu.Unlock()
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		err, ok := r.(error)
+		if !ok {
+			err = fmt.Errorf("%v", r)
+		}
+		er, ok := preprocessError(err, dir, pkgPath, tc.overlay)
+		if ok {
+			errs = append(errs, er)
+		}
+		if !ok || ctx.Err() != nil {
+			// An import may be left half preprocessed in the store.
+			lastPreprocessStore = nil
+		}
+	}()
+
+	store := preprocessStoreFor(ctx, snapshot, tc, dir)
+	// Drop the package preprocessed by the previous run, if any, which the
+	// store would refuse to replace.
+	store.DelObject(&gno.PackageValue{ObjectInfo: gno.ObjectInfo{ID: gno.ObjectIDFromPkgPath(pkgPath)}})
+	preprocessFiles(store, pkgPath, files)
+	return errs
+}
+
+// preprocessStore is a gno store which preprocesses the imported packages
+// without running them, so that their initialization code is not executed.
+type preprocessStore struct {
+	gno.Store
+	// files are the files open in the snapshot the imports are read from,
+	// except the ones of dir, the directory of the checked package.
+	files map[string]*GnoFile
+	dir   string
+	// ctx and tc are the ones of the current run: the imports are resolved
+	// like tc does, and no package is found anymore once ctx is cancelled.
+	ctx context.Context
+	tc  *TypeCheck
+}
+
+// preprocessStoreFor returns the store preprocessing the package located in
+// dir, seen by snapshot. The store of the previous run is reused, along with
+// the imports it preprocessed, if it checked the same package and the files
+// open outside of it didn't change. preprocessMu must be held.
+func preprocessStoreFor(ctx context.Context, snapshot *Snapshot, tc *TypeCheck, dir string) *preprocessStore {
+	files := map[string]*GnoFile{}
+	for name, f := range snapshot.files {
+		if filepath.Dir(name) != dir {
+			files[name] = f
+		}
+	}
+	if ps := lastPreprocessStore; ps != nil && ps.dir == dir && maps.Equal(ps.files, files) {
+		ps.ctx, ps.tc = ctx, tc
+		return ps
+	}
+
+	db := dbm.NewMemDB()
+	baseStore := dbadapter.StoreConstructor(db, stypes.StoreOptions{})
+	iavlStore := iavl.StoreConstructor(db, stypes.StoreOptions{})
+	ps := &preprocessStore{
+		Store: gno.NewStore(nil, baseStore, iavlStore),
+		files: files,
+		dir:   dir,
+		ctx:   ctx,
+		tc:    tc,
+	}
+	ps.SetPackageGetter(func(pkgPath string) (*gno.PackageNode, *gno.PackageValue) {
+		if ps.ctx.Err() != nil {
+			return nil, nil
+		}
+		dir, ok := ps.tc.dirs[pkgPath]
+		if !ok {
+			var err error
+			if dir, err = packageDir(pkgPath); err != nil {
+				return nil, nil
+			}
+		}
+		files, err := gnoFileNodes(ps.tc, dir)
+		if err != nil || len(files) == 0 {
+			return nil, nil
+		}
+		return preprocessFiles(ps, pkgPath, files)
+	})
+	ps.SetNativeStore(stdlibs.NativeStore)
+	stdlibs.InjectNativeMappings(ps)
+	lastPreprocessStore = ps
+	return ps
+}
+
+// preprocessFiles predefines and preprocesses files, the files of the
+// package pkgPath, the way the gno VM does before running them.
+func preprocessFiles(store gno.Store, pkgPath string, files []*gno.FileNode) (*gno.PackageNode, *gno.PackageValue) {
+	fset := &gno.FileSet{Files: files}
+	pn := gno.NewPackageNode(files[0].PkgName, pkgPath, fset)
+	pv := pn.NewPackage()
+	store.SetBlockNode(pn)
+	store.SetCachePackage(pv)
+
+	gno.PredefineFileSet(store, pn, fset)
+	pb := pv.GetBlock(store)
+	for _, fn := range files {
+		fn = gno.Preprocess(store, pn, fn).(*gno.FileNode)
+		gno.SaveBlockNodes(store, fn)
+		fb := gno.NewBlock(fn, pb)
+		fb.Values = make([]gno.TypedValue, len(fn.StaticBlock.Values))
+		copy(fb.Values, fn.StaticBlock.Values)
+		pv.AddFileBlock(fn.Name, fb)
+	}
+	pn.PrepareNewValues(pv)
+	// The variables aren't initialized, give them at least their type, as
+	// expected by the store.
+	values := pv.GetBlock(store).Values
+	for i := range values {
+		if values[i].T == nil && i < len(pn.Types) {
+			values[i].T = pn.Types[i]
+		}
+	}
+	return pn, pv
+}
+
+// gnoFileNodes parses the gno files of the package located in dir, except
+// the test files.
+func gnoFileNodes(tc *TypeCheck, dir string) ([]*gno.FileNode, error) {
+	filenames, err := listGnoFiles(dir, tc.overlay)
+	if err != nil {
+		return nil, err
+	}
+	var files []*gno.FileNode
+	for _, fname := range filenames {
+		if strings.HasSuffix(fname, "_test.gno") || strings.HasSuffix(fname, "_filetest.gno") {
+			continue
+		}
+		src, err := readFile(fname, tc.overlay)
+		if err != nil {
+			return nil, err
+		}
+		fn, err := gno.ParseFile(filepath.Base(fname), string(src))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, fn)
+	}
+	return files, nil
+}
+
+// go2gno converts f to the AST of gno, like gno.ParseFile does.
+func go2gno(fset *token.FileSet, f *ast.File) (fn *gno.FileNode, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return gno.Go2Gno(fset, f).(*gno.FileNode), nil
+}
+
+// setDeclLines sets the lines of the declarations of fn, converted from f,
+// which gno leaves unset for the specs of the general declarations. The
+// preprocessor locates its errors with them.
+func setDeclLines(fset *token.FileSet, f *ast.File, fn *gno.FileNode) {
+	var lines []int
+	for _, decl := range f.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok {
+			for _, spec := range gd.Specs {
+				lines = append(lines, fset.Position(spec.Pos()).Line)
+			}
+		} else {
+			lines = append(lines, fset.Position(decl.Pos()).Line)
+		}
+	}
+	if len(lines) != len(fn.Decls) {
+		return
+	}
+	for i, decl := range fn.Decls {
+		if decl.GetLine() == 0 {
+			decl.SetLine(lines[i])
+		}
+	}
+}
+
+// convertErrorRe matches the errors of gno when converting a go node it
+// doesn't support, e.g. `unknown Go type *ast.GoStmt: (*ast.GoStmt)(...)`.
+var convertErrorRe = regexp.MustCompile(`unknown Go type (\*ast\.\w+)`)
+
+// convertError converts err, an error of gno when converting f to its own
+// AST, to an error located on the first node gno doesn't support. f is read
+// from overlay if open.
+func convertError(err error, fset *token.FileSet, f *ast.File, overlay map[string][]byte) ErrorInfo {
+	// Errors are dumped along with the go node, keep the first line.
+	msg, _, _ := strings.Cut(err.Error(), "\n")
+	var node ast.Node = f.Name
+	if m := convertErrorRe.FindStringSubmatch(msg); m != nil {
+		msg = m[0]
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n != nil && fmt.Sprintf("%T", n) == m[1] {
+				node = n
+			}
+			return node == f.Name
+		})
+	}
+	return ErrorInfo{
+		FileName: fset.Position(f.Pos()).Filename,
+		Range:    nodeToRange(fset, node, overlay),
+		Msg:      msg,
+		Tool:     "gno",
+	}
+}
+
+// preprocessError converts err, an error of the gno preprocessor, to an
+// error located in a file of dir: on the token at its column if any,
+// otherwise on its line. The preprocessor wraps the error with the
+// locations of the enclosing nodes, the innermost located in the package
+// pkgPath is kept. false is returned if err has no location in the package.
+func preprocessError(err error, dir, pkgPath string, overlay map[string][]byte) (ErrorInfo, bool) {
+	msg := err.Error()
+	var (
+		filename string
+		line     int
+		column   int
+		end      int
+	)
+	for _, m := range preprocessErrorRe.FindAllStringSubmatchIndex(msg, -1) {
+		loc := msg[m[2]:m[3]]
+		if filepath.Dir(loc) != pkgPath {
+			continue
+		}
+		filename = filepath.Join(dir, filepath.Base(loc))
+		line, _ = strconv.Atoi(msg[m[4]:m[5]])
+		column = 0
+		if m[6] >= 0 {
+			column, _ = strconv.Atoi(msg[m[6]:m[7]])
+		}
+		end = m[1]
+	}
+	if filename == "" || line == 0 {
+		return ErrorInfo{}, false
+	}
+	// Keep the message following the innermost location.
+	msg = strings.TrimSpace(preprocessErrorRe.ReplaceAllString(msg[end:], ""))
+	msg, _, _ = strings.Cut(msg, "\n")
+	if msg == "" {
+		return ErrorInfo{}, false
+	}
+	src, err := readFile(filename, overlay)
+	if err != nil {
+		return ErrorInfo{}, false
+	}
+	rng, err := lineRange(src, line)
+	if err != nil {
+		return ErrorInfo{}, false
+	}
+	if column > 0 {
+		rng = columnRange(src, rng, column)
+	}
+	return ErrorInfo{
+		FileName: filename,
+		Range:    rng,
+		Msg:      msg,
+		Tool:     "gno",
+	}, true
+}
+
+// columnRange returns the range of the token found at column (1-based, in
+// bytes) of the line of src whose range is rng. It's empty if no token
+// starts there, and rng is returned if column is out of the line.
+func columnRange(src []byte, rng protocol.Range, column int) protocol.Range {
+	lineStart, err := positionToOffset(src, protocol.Position{Line: rng.Start.Line})
+	if err != nil {
+		return rng
+	}
+	offset := lineStart + column - 1
+	lineEnd, _ := positionToOffset(src, rng.End)
+	if offset > lineEnd {
+		return rng
+	}
+	start, err := offsetToPosition(src, offset)
+	if err != nil {
+		return rng
+	}
+	end, err := offsetToPosition(src, offset+tokenLen(src, offset))
+	if err != nil {
+		return rng
+	}
+	return protocol.Range{Start: start, End: end}
+}
+
+// lineRange returns the range of the text of the given line (1-based) of
+// src, without its indentation.
+func lineRange(src []byte, line int) (protocol.Range, error) {
+	lines := strings.Split(string(src), "\n")
+	if line > len(lines) {
+		return protocol.Range{}, errors.New("line out of range")
+	}
+	text := strings.TrimRight(lines[line-1], " \t\r")
+	indent := len(text) - len(strings.TrimLeft(text, " \t"))
+	start := protocol.Position{Line: uint32(line - 1), Character: utf16Len([]byte(text[:indent]))}
+	end := protocol.Position{Line: uint32(line - 1), Character: utf16Len([]byte(text))}
+	return protocol.Range{Start: start, End: end}, nil
+}
@@ -0,0 +1,61 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestDiagnosePreprocess(t *testing.T) {
+	s, dir := newTestServer(t, map[string]string{
+		"gno.mod": "module gno.land/r/test/pp\n",
+		"pp.gno":  "package pp\n\nvar c = 1i\n",
+	})
+	filename := filepath.Join(dir, "pp.gno")
+	want := ErrorInfo{
+		FileName: filename,
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 2, Character: 0},
+			End:   protocol.Position{Line: 2, Character: 10},
+		},
+		Msg:  "imaginaries are not supported",
+		Tool: "gno",
+	}
+	// The second run reuses the store of the first one.
+	var store *preprocessStore
+	for i := 0; i < 2; i++ {
+		res, err := s.diagnose(context.Background(), s.getSnapshot(), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errs := res[filename]; !reflect.DeepEqual(errs, []ErrorInfo{want}) {
+			t.Fatalf("run %d: got %v, want %v", i, errs, want)
+		}
+		if i == 1 && lastPreprocessStore != store {
+			t.Errorf("the preprocess store was not reused")
+		}
+		store = lastPreprocessStore
+	}
+}
+
+func TestPreprocessErrorColumn(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "pp.gno")
+	overlay := map[string][]byte{filename: []byte("package pp\n\nvar é = 1i\n")}
+	err := errors.New("gno.land/r/test/pp/pp.gno:3:10: imaginaries are not supported")
+	got, ok := preprocessError(err, dir, "gno.land/r/test/pp", overlay)
+	if !ok {
+		t.Fatal("no error located")
+	}
+	want := protocol.Range{
+		Start: protocol.Position{Line: 2, Character: 8},
+		End:   protocol.Position{Line: 2, Character: 10},
+	}
+	if got.Range != want || got.Msg != "imaginaries are not supported" {
+		t.Errorf("got %q at %v, want %q at %v", got.Msg, got.Range, "imaginaries are not supported", want)
+	}
+}
@@ -0,0 +1,399 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+func (s *server) References(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.ReferenceParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	refs, err := s.findReferences(ctx, snapshot, file, params.Position)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+
+	locations := []protocol.Location{}
+	for _, ref := range refs.idents {
+		if ref.isDecl && !params.Context.IncludeDeclaration {
+			continue
+		}
+		locations = append(locations, nodeToLocation(refs.fset, ref.ident, refs.overlay))
+	}
+	return reply(ctx, locations, nil)
+}
+
+// references holds all the identifiers referring to obj.
+type references struct {
+	obj  types.Object
+	fset *token.FileSet
+	// overlay holds the files open in the snapshot the references were
+	// found in.
+	overlay map[string][]byte
+	idents  []*reference
+}
+
+type reference struct {
+	ident  *ast.Ident
+	isDecl bool
+	// unit is the result of the type-check the ident belongs to.
+	unit *TypeCheckResult
+}
+
+// findReferences type-checks the package of file along with its test files,
+// and the workspace packages importing it, then returns every identifier
+// referring to the object found at pos in file.
+func (s *server) findReferences(ctx context.Context, snapshot *Snapshot, file *GnoFile, pos protocol.Position) (*references, error) {
+	filename := file.URI.Filename()
+	dir := filepath.Dir(filename)
+
+	tc, _ := s.newTypeCheck(snapshot)
+	wsDirs := maps.Clone(tc.dirs)
+
+	units, err := checkPackageDir(ctx, tc, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := objectAt(tc.fset, units, filename, file.PositionToOffset(pos))
+	if err != nil {
+		return nil, err
+	}
+	if obj.Pkg() == nil || !obj.Pos().IsValid() {
+		return nil, fmt.Errorf("no references for builtin %s", obj.Name())
+	}
+
+	// Exported objects can be referenced by other packages, look into the
+	// declaring package and the workspace packages importing it.
+	if obj.Exported() && !isLocal(obj) {
+		pkgPath := obj.Pkg().Path()
+		for path, wsDir := range wsDirs {
+			if wsDir == dir {
+				continue
+			}
+			if path != pkgPath && !importsPath(tc.overlay, wsDir, pkgPath) {
+				continue
+			}
+			wsUnits, err := checkPackageDir(ctx, tc, wsDir)
+			if err != nil {
+				continue
+			}
+			units = append(units, wsUnits...)
+		}
+	}
+
+	key := objectKey(tc.fset, obj)
+	seen := map[token.Pos]bool{}
+	refs := &references{obj: obj, fset: tc.fset, overlay: tc.overlay}
+	for _, unit := range units {
+		add := func(id *ast.Ident, o types.Object, isDecl bool) {
+			if o == nil || seen[id.Pos()] || objectKey(tc.fset, o) != key {
+				return
+			}
+			seen[id.Pos()] = true
+			refs.idents = append(refs.idents, &reference{ident: id, isDecl: isDecl, unit: unit})
+		}
+		for id, o := range unit.info.Defs {
+			add(id, o, true)
+		}
+		for id, o := range unit.info.Uses {
+			add(id, o, false)
+		}
+	}
+
+	sort.Slice(refs.idents, func(i, j int) bool {
+		pi := tc.fset.Position(refs.idents[i].ident.Pos())
+		pj := tc.fset.Position(refs.idents[j].ident.Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return refs, nil
+}
+
+// checkPackageDir type-checks the package located in dir. Regular files and
+// `_test.gno` files of the same package are checked together, whereas
+// external test files (package `xxx_test`) and each `_filetest.gno` file are
+// checked separately, as they form their own package.
+func checkPackageDir(ctx context.Context, tc *TypeCheck, dir string) ([]*TypeCheckResult, error) {
+	filenames, err := listGnoFiles(dir, tc.overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgName string
+	var files, tests, xtests, filetests []*FileInfo
+	for _, fname := range filenames {
+		bsrc, err := readFile(fname, tc.overlay)
+		if err != nil {
+			return nil, err
+		}
+		f := &FileInfo{Name: filepath.Base(fname), Body: string(bsrc)}
+		switch {
+		case strings.HasSuffix(fname, "_filetest.gno"):
+			filetests = append(filetests, f)
+		case strings.HasSuffix(fname, "_test.gno"):
+			tests = append(tests, f)
+		default:
+			if pkgName == "" {
+				pkgName = packageName(f)
+			}
+			files = append(files, f)
+		}
+	}
+	for _, f := range tests {
+		if name := packageName(f); name != pkgName && strings.HasSuffix(name, "_test") {
+			xtests = append(xtests, f)
+		} else {
+			files = append(files, f)
+		}
+	}
+
+	importPath := importPathFromGnoMod(dir)
+	if importPath != "" {
+		tc.dirs[importPath] = dir
+	}
+
+	var res []*TypeCheckResult
+	if len(files) > 0 {
+		pi := &PackageInfo{Dir: dir, ImportPath: importPath, Files: files}
+		pkg := pi.TypeCheck(ctx, tc)
+		if importPath != "" {
+			// Imported by the external tests and filetests
+			tc.cache[importPath] = pkg
+		}
+		res = append(res, pkg)
+	}
+	if len(xtests) > 0 {
+		pi := &PackageInfo{Dir: dir, ImportPath: importPath + "_test", Files: xtests}
+		res = append(res, pi.TypeCheck(ctx, tc))
+	}
+	for _, f := range filetests {
+		pi := &PackageInfo{Dir: dir, ImportPath: "main", Files: []*FileInfo{f}}
+		res = append(res, pi.TypeCheck(ctx, tc))
+	}
+	return res, nil
+}
+
+// checkFile returns the type-check result of the package of file, from the
+// cache of snapshot, and the parsed file of file. The returned TypeCheck is
+// shared with the cache and must only be read. The test files, not part of
+// the cached packages, are type-checked again.
+func (s *server) checkFile(ctx context.Context, snapshot *Snapshot, file *GnoFile) (*TypeCheck, *TypeCheckResult, *ast.File, error) {
+	filename := file.URI.Filename()
+	if !strings.HasSuffix(filename, "_test.gno") && !strings.HasSuffix(filename, "_filetest.gno") {
+		pkg, ok := s.getPackage(ctx, snapshot, filepath.Dir(filename))
+		// An older result doesn't match the content of file.
+		if ok && pkg.snapshotID == snapshot.ID() && pkg.TypeCheckResult != nil {
+			res := pkg.TypeCheckResult
+			if unit, f := unitOf(res.fset, []*TypeCheckResult{res}, filename); f != nil {
+				return res.tc, unit, f, nil
+			}
+		}
+	}
+	tc, _ := s.newTypeCheck(snapshot)
+	unit, f, err := checkFileWith(ctx, tc, file)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tc, unit, f, nil
+}
+
+// checkFileWith type-checks the package of file with tc, and returns the
+// type-check result and the parsed file of file. Unlike the one returned by
+// checkFile, tc can go on importing packages, whose objects can then be
+// compared with the ones of file.
+func checkFileWith(ctx context.Context, tc *TypeCheck, file *GnoFile) (*TypeCheckResult, *ast.File, error) {
+	filename := file.URI.Filename()
+	units, err := checkPackageDir(ctx, tc, filepath.Dir(filename))
+	if err != nil {
+		return nil, nil, err
+	}
+	unit, f := unitOf(tc.fset, units, filename)
+	if f == nil {
+		return nil, nil, errors.New("file not found in package")
+	}
+	return unit, f, nil
+}
+
+// objectAt returns the object denoted by the identifier found at offset in
+// filename.
+func objectAt(fset *token.FileSet, units []*TypeCheckResult, filename string, offset int) (types.Object, error) {
+	for _, unit := range units {
+		for _, f := range unit.files {
+			tokFile := fset.File(f.Pos())
+			if tokFile == nil || tokFile.Name() != filename {
+				continue
+			}
+			obj, _, err := objectNodeAt(fset, unit, f, offset)
+			return obj, err
+		}
+	}
+	return nil, fmt.Errorf("file %s not found in package", filename)
+}
+
+// objectNodeAt returns the object denoted by the identifier found at offset
+// in f, a file of unit, along with the node denoting it: the identifier, or
+// the path of an import spec.
+func objectNodeAt(fset *token.FileSet, unit *TypeCheckResult, f *ast.File, offset int) (types.Object, ast.Node, error) {
+	tokFile := fset.File(f.Pos())
+	if offset < 0 || offset > tokFile.Size() {
+		return nil, nil, errors.New("position out of range")
+	}
+	paths := pathEnclosingObjNode(f, tokFile.Pos(offset))
+	if len(paths) == 0 {
+		return nil, nil, errors.New("no identifier found")
+	}
+	var (
+		obj  types.Object
+		node ast.Node
+	)
+	switch n := paths[0].(type) {
+	case *ast.Ident:
+		obj = unit.info.Defs[n]
+		if obj == nil {
+			obj = unit.info.Uses[n]
+		}
+		node = n
+	case *ast.ImportSpec:
+		obj = unit.info.Implicits[n]
+		node = n.Path
+	}
+	if obj == nil {
+		return nil, nil, errors.New("no identifier found")
+	}
+	return obj, node, nil
+}
+
+// objectOf returns the object denoted by id in pgf, or nil. id is resolved
+// using pkg, the type-checked package of pgf.
+func objectOf(pkg *Package, pgf *ParsedGnoFile, id *ast.Ident) types.Object {
+	tcr := pkg.TypeCheckResult
+	if tcr == nil {
+		return nil
+	}
+	offset := pgf.Fset.Position(id.Pos()).Offset
+	obj, err := objectAt(tcr.fset, []*TypeCheckResult{tcr}, pgf.URI.Filename(), offset)
+	if err != nil || obj.Name() != id.Name {
+		return nil
+	}
+	return obj
+}
+
+// importedPath returns the import path of the package denoted by id in pgf,
+// e.g. `avl` in `avl.Tree`, or "" if id doesn't denote an imported package.
+// pkg is the type-checked package of pgf, so renamed imports are handled.
+func importedPath(pkg *Package, pgf *ParsedGnoFile, id *ast.Ident) string {
+	if obj := objectOf(pkg, pgf, id); obj != nil {
+		if pn, ok := obj.(*types.PkgName); ok {
+			return pn.Imported().Path()
+		}
+		return ""
+	}
+	// The file may not be part of the type-checked package, like test
+	// files.
+	for _, spec := range pgf.File.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == id.Name {
+			return path
+		}
+	}
+	return ""
+}
+
+// typeNameOf returns the declaration of the named type t or *t, or nil if t
+// isn't named.
+func typeNameOf(t types.Type) *types.TypeName {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj()
+	}
+	return nil
+}
+
+// objectKey identifies obj by its declaration position, which is shared by
+// all the type-checks of the same source files, while types.Object values
+// differ from one type-check to another.
+func objectKey(fset *token.FileSet, obj types.Object) string {
+	posn := fset.Position(obj.Pos())
+	return fmt.Sprintf("%s:%d:%s", posn.Filename, posn.Offset, obj.Name())
+}
+
+// isLocal returns true if obj is declared inside a function or is a file
+// scoped object (like imports).
+func isLocal(obj types.Object) bool {
+	if obj.Parent() == nil { // fields, methods and labels
+		_, isLabel := obj.(*types.Label)
+		return isLabel
+	}
+	return obj.Parent() != obj.Pkg().Scope()
+}
+
+// importsPath returns true if one of the gno files in dir imports path.
+// Files present in overlay are read from it rather than from disk.
+func importsPath(overlay map[string][]byte, dir, path string) bool {
+	filenames, err := listGnoFiles(dir, overlay)
+	if err != nil {
+		return false
+	}
+	fset := token.NewFileSet()
+	for _, fname := range filenames {
+		src, err := readFile(fname, overlay)
+		if err != nil {
+			continue
+		}
+		f, err := parser.ParseFile(fset, fname, src, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, spec := range f.Imports {
+			if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// packageName returns the package name declared by f.
+func packageName(f *FileInfo) string {
+	file, err := parser.ParseFile(token.NewFileSet(), f.Name, f.Body, parser.PackageClauseOnly)
+	if err != nil {
+		return ""
+	}
+	return file.Name.Name
+}
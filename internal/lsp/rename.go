@@ -0,0 +1,279 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+func (s *server) PrepareRename(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.PrepareRenameParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	refs, err := s.findReferences(ctx, snapshot, file, params.Position)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	if err := s.checkRenamable(refs); err != nil {
+		return reply(ctx, nil, err)
+	}
+
+	// Return the range of the identifier under the cursor
+	for _, ref := range refs.idents {
+		loc := nodeToLocation(refs.fset, ref.ident, refs.overlay)
+		if loc.URI.Filename() != uri.Filename() {
+			continue
+		}
+		if rangeContains(loc.Range, params.Position) {
+			return reply(ctx, loc.Range, nil)
+		}
+	}
+	return reply(ctx, nil, nil)
+}
+
+func (s *server) Rename(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.RenameParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	newName := params.NewName
+	if !token.IsIdentifier(newName) {
+		return reply(ctx, nil, fmt.Errorf("invalid identifier %q", newName))
+	}
+
+	refs, err := s.findReferences(ctx, snapshot, file, params.Position)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	if err := s.checkRenamable(refs); err != nil {
+		return reply(ctx, nil, err)
+	}
+	if refs.obj.Name() == newName {
+		return reply(ctx, protocol.WorkspaceEdit{}, nil)
+	}
+	if err := checkRenameConflicts(refs, newName); err != nil {
+		return reply(ctx, nil, err)
+	}
+
+	changes := map[protocol.DocumentURI][]protocol.TextEdit{}
+	for _, ref := range refs.idents {
+		loc := nodeToLocation(refs.fset, ref.ident, refs.overlay)
+		changes[loc.URI] = append(changes[loc.URI], protocol.TextEdit{
+			Range:   loc.Range,
+			NewText: newName,
+		})
+	}
+	return reply(ctx, protocol.WorkspaceEdit{Changes: changes}, nil)
+}
+
+// checkRenamable returns an error if the object referred by refs can't be
+// renamed, whatever the new name is.
+func (s *server) checkRenamable(refs *references) error {
+	obj := refs.obj
+	declFile := refs.fset.Position(obj.Pos()).Filename
+	if s.env.GNOROOT != "" {
+		gnoroot := filepath.Clean(s.env.GNOROOT) + string(filepath.Separator)
+		if strings.HasPrefix(declFile, gnoroot) {
+			return fmt.Errorf("cannot rename %s: declared in GNOROOT", obj.Name())
+		}
+	}
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return fmt.Errorf("cannot rename imported package %s", obj.Name())
+	case *types.Var:
+		if obj.Embedded() {
+			return fmt.Errorf("cannot rename embedded field %s, rename its type instead", obj.Name())
+		}
+	case *types.Func:
+		if obj.Parent() == obj.Pkg().Scope() && (obj.Name() == "init" || obj.Name() == "main") {
+			return fmt.Errorf("cannot rename %s function", obj.Name())
+		}
+	}
+	return nil
+}
+
+// checkRenameConflicts returns an error if renaming refs.obj to newName
+// would result in a redeclaration, would shadow or be shadowed by another
+// declaration, or would break references from other packages.
+func checkRenameConflicts(refs *references, newName string) error {
+	obj := refs.obj
+	fset := refs.fset
+	key := objectKey(fset, obj)
+	pkgPath := obj.Pkg().Path()
+
+	conflict := func(other types.Object, format string) error {
+		posn := fset.Position(other.Pos())
+		msg := fmt.Sprintf(format, obj.Name(), newName)
+		if posn.IsValid() {
+			return fmt.Errorf("%s (declared at %s)", msg, posn)
+		}
+		return errors.New(msg)
+	}
+
+	// Check references from other packages (including external tests and
+	// filetests) remain valid.
+	if obj.Exported() && !token.IsExported(newName) {
+		for _, ref := range refs.idents {
+			if ref.unit.pkg.Path() != pkgPath {
+				return fmt.Errorf("cannot rename %s to %s: it is used by %s",
+					obj.Name(), newName, fset.Position(ref.ident.Pos()).Filename)
+			}
+		}
+	}
+
+	switch {
+	case isFieldOrMethod(obj):
+		for _, ref := range refs.idents {
+			if !ref.isDecl {
+				continue
+			}
+			if recv := receiverOf(fset, ref.unit, obj); recv != nil {
+				if other, _, _ := types.LookupFieldOrMethod(recv, true, ref.unit.pkg, newName); other != nil {
+					return conflict(other, "renaming %s to %s conflicts with existing field or method")
+				}
+			}
+		}
+		return nil
+
+	default:
+		if other := obj.Parent().Lookup(newName); other != nil {
+			return conflict(other, "renaming %s to %s conflicts with existing declaration")
+		}
+	}
+
+	pkgLevel := obj.Parent() == obj.Pkg().Scope()
+	units := map[*TypeCheckResult]bool{}
+	for _, ref := range refs.idents {
+		if ref.unit.pkg.Path() != pkgPath {
+			continue
+		}
+		units[ref.unit] = true
+
+		// After renaming, the reference must not be shadowed by an inner
+		// declaration of newName.
+		scope := ref.unit.pkg.Scope().Innermost(ref.ident.Pos())
+		if scope == nil {
+			continue
+		}
+		_, other := scope.LookupParent(newName, ref.ident.Pos())
+		if other == nil || objectKey(fset, other) == key {
+			continue
+		}
+		if other.Parent() != obj.Parent() && !isEnclosingScope(other.Parent(), obj.Parent()) {
+			return conflict(other, "renaming %s to %s would be shadowed by another declaration")
+		}
+	}
+
+	// Existing references to newName must not be captured by obj once
+	// renamed.
+	for unit := range units {
+		// File scope declarations (imports) conflict with package ones.
+		if pkgLevel {
+			for _, f := range unit.files {
+				if scope := unit.info.Scopes[f]; scope != nil {
+					if other := scope.Lookup(newName); other != nil {
+						return conflict(other, "renaming %s to %s conflicts with import")
+					}
+				}
+			}
+		}
+		for id, other := range unit.info.Uses {
+			if other.Name() != newName {
+				continue
+			}
+			if !pkgLevel && (!obj.Parent().Contains(id.Pos()) || id.Pos() < obj.Pos()) {
+				continue // not in the scope of obj
+			}
+			if isEnclosingScope(other.Parent(), obj.Parent()) {
+				return conflict(other, "renaming %s to %s would shadow another declaration")
+			}
+		}
+	}
+	return nil
+}
+
+// receiverOf returns the type which declares the method or the field obj.
+func receiverOf(fset *token.FileSet, unit *TypeCheckResult, obj types.Object) types.Type {
+	if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+		return sig.Recv().Type()
+	}
+	key := objectKey(fset, obj)
+	for _, def := range unit.info.Defs {
+		tn, ok := def.(*types.TypeName)
+		if !ok {
+			continue
+		}
+		st, ok := tn.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if objectKey(fset, st.Field(i)) == key {
+				return tn.Type()
+			}
+		}
+	}
+	return nil
+}
+
+// isEnclosingScope returns true if outer is a strict parent of inner.
+func isEnclosingScope(outer, inner *types.Scope) bool {
+	if outer == nil {
+		return false
+	}
+	for s := inner.Parent(); s != nil; s = s.Parent() {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
+
+func isFieldOrMethod(obj types.Object) bool {
+	switch obj := obj.(type) {
+	case *types.Var:
+		return obj.IsField()
+	case *types.Func:
+		return obj.Type().(*types.Signature).Recv() != nil
+	}
+	return false
+}
+
+// rangeContains returns true if pos is inside r, end included.
+func rangeContains(r protocol.Range, pos protocol.Position) bool {
+	if pos.Line < r.Start.Line || pos.Line > r.End.Line {
+		return false
+	}
+	if pos.Line == r.Start.Line && pos.Character < r.Start.Character {
+		return false
+	}
+	if pos.Line == r.End.Line && pos.Character > r.End.Character {
+		return false
+	}
+	return true
+}
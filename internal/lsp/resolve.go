@@ -0,0 +1,160 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+// completionData is the data of the completion items whose details are
+// computed on resolve: it designates the object of the item.
+type completionData struct {
+	// URI is the file being completed.
+	URI uri.URI `json:"uri"`
+	// Pkg is the import path of the package of the object.
+	Pkg string `json:"pkg"`
+	// Recv is the name of the type of which the object is a field or method,
+	// if any.
+	Recv string `json:"recv,omitempty"`
+	Name string `json:"name"`
+	// Import is true if Pkg has to be imported by the file.
+	Import bool `json:"import,omitempty"`
+}
+
+// memberData returns the data of the completion item of the field or method
+// name of t, or nil if t isn't a package-level named type or a pointer to
+// it.
+func memberData(t types.Type, name string) *completionData {
+	tn := typeNameOf(t)
+	// Only the package-level types can be looked up on resolve.
+	if tn == nil || tn.Pkg() == nil || tn.Parent() != tn.Pkg().Scope() {
+		return nil
+	}
+	return &completionData{Pkg: tn.Pkg().Path(), Recv: tn.Name(), Name: name}
+}
+
+// withURI sets the file being completed in the data of the items of list.
+func withURI(list *protocol.CompletionList, u uri.URI) *protocol.CompletionList {
+	for _, item := range list.Items {
+		if data, ok := item.Data.(*completionData); ok {
+			data.URI = u
+		}
+	}
+	return list
+}
+
+func (s *server) CompletionResolve(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var item protocol.CompletionItem
+	if err := json.Unmarshal(req.Params(), &item); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+	if item.Data == nil {
+		return reply(ctx, item, nil)
+	}
+	// Decode the data, which was unmarshaled as a map.
+	var data completionData
+	b, _ := json.Marshal(item.Data)
+	if err := json.Unmarshal(b, &data); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	if err := s.resolveCompletionItem(ctx, &item, data); err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, item, nil)
+}
+
+// resolvePackage returns the type-check result of the package of the given
+// import path, seen from filename located in dir. The cached results are
+// used when available.
+func (s *server) resolvePackage(ctx context.Context, snapshot *Snapshot, dir, filename, path string) *TypeCheckResult {
+	if path == importPathFromGnoMod(dir) {
+		if strings.HasSuffix(filename, "_test.gno") || strings.HasSuffix(filename, "_filetest.gno") {
+			// The cached package doesn't include the test files.
+			tc, _ := s.newTypeCheck(snapshot)
+			units, err := checkPackageDir(ctx, tc, dir)
+			if err != nil {
+				return nil
+			}
+			unit, _ := unitOf(tc.fset, units, filename)
+			return unit
+		}
+		if pkg, ok := s.getPackage(ctx, snapshot, dir); ok {
+			return pkg.TypeCheckResult
+		}
+		return nil
+	}
+	if pkg := s.lookupPackage(ctx, snapshot, path); pkg != nil && pkg.TypeCheckResult != nil {
+		return pkg.TypeCheckResult
+	}
+	tc, _ := s.newTypeCheck(snapshot)
+	if _, err := tc.Import(ctx, path); err != nil {
+		return nil
+	}
+	return tc.cache[path]
+}
+
+// resolveCompletionItem fills in the full signature and the documentation
+// of the object designated by data, and the edits importing its package.
+func (s *server) resolveCompletionItem(ctx context.Context, item *protocol.CompletionItem, data completionData) error {
+	snapshot := s.getSnapshot()
+	filename := data.URI.Filename()
+	file, ok := snapshot.Get(filename)
+	if !ok {
+		return errors.New("snapshot not found")
+	}
+
+	if data.Import && item.AdditionalTextEdits == nil {
+		if pgf, _ := file.ParseGno(ctx); pgf != nil {
+			item.AdditionalTextEdits = addImportEdits(pgf.Fset, pgf.File, data.Pkg, pgf.overlay())
+		}
+	}
+
+	dir := filepath.Dir(filename)
+	self := importPathFromGnoMod(dir)
+	res := s.resolvePackage(ctx, snapshot, dir, filename, data.Pkg)
+	if res == nil || res.pkg == nil {
+		return nil
+	}
+	pkg, tc := res.pkg, res.tc
+
+	var obj types.Object
+	if data.Recv != "" {
+		tn, ok := pkg.Scope().Lookup(data.Recv).(*types.TypeName)
+		if !ok {
+			return nil
+		}
+		obj, _, _ = types.LookupFieldOrMethod(tn.Type(), true, pkg, data.Name)
+	} else {
+		obj = pkg.Scope().Lookup(data.Name)
+	}
+	if obj == nil {
+		return nil
+	}
+
+	qf := func(p *types.Package) string {
+		if p.Path() == self {
+			return ""
+		}
+		return p.Name()
+	}
+	item.Detail = types.ObjectString(obj, qf)
+	if data.Import {
+		item.Detail += fmt.Sprintf(" (from %q)", data.Pkg)
+	}
+	if doc := objectDoc(tc, obj); doc != "" {
+		item.Documentation = protocol.MarkupContent{
+			Kind:  protocol.Markdown,
+			Value: doc,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,539 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+
+	"github.com/gnolang/gno/gnovm/pkg/gnolang"
+	"go.lsp.dev/protocol"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Scores of the completion candidates, the items are sorted by decreasing
+// score.
+const (
+	scoreKeyword  = 0.7
+	scoreBuiltin  = 0.8
+	scorePkgName  = 0.9
+	scoreGlobal   = 1.0
+	scoreLocal    = 1.1
+	scoreDeep     = 1.5 // member of a variable, matching the expected type
+	scoreExpected = 2.0 // matches the expected type
+	scoreContext  = 3.0 // field of a composite literal, constant of a switch tag
+)
+
+// statementKeywords are the keywords starting a statement, gno doesn't
+// support goroutines nor select statements.
+var statementKeywords = []string{
+	"break", "const", "continue", "defer", "fallthrough", "for", "goto",
+	"if", "return", "switch", "type", "var",
+}
+
+// declKeywords are the keywords starting a top-level declaration.
+var declKeywords = []string{"const", "func", "import", "type", "var"}
+
+// scopeCompletion returns the completion of the identifier prefix being
+// written at offset of filename, which isn't a selector: the objects in
+// scope, the unset fields of a composite literal, the constants of the type
+// of a switch tag and the keywords. The items matching the type expected at
+// offset come first. tcr is the type-check result holding f, the parsed
+// file of filename.
+func (s *server) scopeCompletion(tcr *TypeCheckResult, f *ast.File, filename string, offset int, prefix string) *protocol.CompletionList {
+	if tcr.pkg == nil {
+		return s.completionList(nil, prefix)
+	}
+	tokFile := tcr.fset.File(f.Pos())
+	if offset > tokFile.Size() {
+		return s.completionList(nil, prefix)
+	}
+	pos := tokFile.Pos(offset - len(prefix))
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	if len(path) == 0 {
+		return s.completionList(nil, prefix)
+	}
+
+	c := &completer{s: s, info: tcr.info, pkg: tcr.pkg, pos: pos}
+
+	// Top-level declarations, e.g. `fu` parsed as a bad declaration.
+	if _, ok := path[0].(*ast.BadDecl); ok || len(path) == 1 {
+		realm := gnolang.IsRealmPath(importPathFromGnoMod(filepath.Dir(filename)))
+		c.keywords(declKeywords)
+		c.addItems(s.snippetItems(declSnippets, prefix, realm), scoreKeyword)
+		return s.completionList(c.candidates, prefix)
+	}
+
+	// The results of `return ` being written aren't part of the statement.
+	if ret := bareReturnBefore(tokFile, path, pos); ret != nil {
+		path = append([]ast.Node{ret}, path...)
+	}
+	c.expected = expectedType(c.info, path, pos)
+	c.compositeLitFields(path)
+	c.switchConstants(path)
+	c.scopeObjects()
+	c.addItems(s.builtinItems(prefix), scoreBuiltin)
+	if isStatementStart(path, pos) {
+		c.keywords(statementKeywords)
+		c.addItems(s.snippetItems(statementSnippets, prefix, false), scoreKeyword)
+	}
+	return s.completionList(c.candidates, prefix)
+}
+
+// completer collects the completion candidates of an identifier.
+type completer struct {
+	s    *server
+	info *types.Info
+	pkg  *types.Package
+	pos  token.Pos
+	// expected is the type expected at pos, or nil if unknown.
+	expected types.Type
+
+	candidates []candidate
+	seen       map[string]bool
+}
+
+// add adds item with the given score, unless its label was already added.
+func (c *completer) add(item protocol.CompletionItem, score float64) {
+	if c.seen[item.Label] {
+		return
+	}
+	if c.seen == nil {
+		c.seen = map[string]bool{}
+	}
+	c.seen[item.Label] = true
+	c.candidates = append(c.candidates, candidate{item: item, score: score})
+}
+
+func (c *completer) addItems(items []protocol.CompletionItem, score float64) {
+	for _, item := range items {
+		c.add(item, score)
+	}
+}
+
+func (c *completer) keywords(keywords []string) {
+	for _, kw := range keywords {
+		c.add(protocol.CompletionItem{
+			Label:      kw,
+			InsertText: kw,
+			Kind:       protocol.CompletionItemKindKeyword,
+		}, scoreKeyword)
+	}
+}
+
+func (c *completer) qualifier(p *types.Package) string {
+	if p == c.pkg {
+		return ""
+	}
+	return p.Name()
+}
+
+// scopeObjects adds the objects in scope at c.pos, the innermost shadowing
+// the outermost. The variables of the structs in scope are also looked
+// into, for fields and methods matching the expected type.
+func (c *completer) scopeObjects() {
+	pkgScope := c.pkg.Scope()
+	scope := pkgScope.Innermost(c.pos)
+	for ; scope != nil && scope != types.Universe; scope = scope.Parent() {
+		local := scope != pkgScope && scope.Parent() != pkgScope
+		for _, name := range scope.Names() {
+			// Skip the objects shadowed by an inner scope, or not declared
+			// yet at c.pos.
+			if inner, _ := scope.LookupParent(name, c.pos); name == "_" || inner != scope {
+				continue
+			}
+			obj := scope.Lookup(name)
+
+			score := scoreGlobal
+			switch {
+			case local:
+				score = scoreLocal
+			case scope != pkgScope:
+				score = scorePkgName
+			}
+			if c.matchesExpected(obj) {
+				score = scoreExpected
+			}
+			if !local {
+				score = exportedScore(name, score)
+			}
+			c.add(c.objectItem(obj, name), score)
+			if v, ok := obj.(*types.Var); ok {
+				c.deepMembers(v)
+			}
+		}
+	}
+}
+
+// deepMembers adds the fields and methods of v which match the expected
+// type, e.g. `u.Name` where a string is expected.
+func (c *completer) deepMembers(v *types.Var) {
+	if c.expected == nil {
+		return
+	}
+	if _, ok := underlying(v.Type()).(*types.Struct); !ok {
+		return
+	}
+	for _, item := range c.s.memberItems(v.Type(), true, c.pkg) {
+		obj, _, _ := types.LookupFieldOrMethod(v.Type(), true, c.pkg, item.Label)
+		if obj == nil || !c.matchesExpected(obj) {
+			continue
+		}
+		if fn, ok := obj.(*types.Func); ok && fn.Type().(*types.Signature).Params().Len() > 0 {
+			continue
+		}
+		item.Label = v.Name() + "." + item.Label
+		item.InsertText = v.Name() + "." + item.InsertText
+		c.add(item, exportedScore(obj.Name(), scoreDeep))
+	}
+}
+
+// matchesExpected reports whether obj, or the result of the function obj,
+// is assignable to the expected type.
+func (c *completer) matchesExpected(obj types.Object) bool {
+	if c.expected == nil || c.expected == types.Typ[types.Invalid] {
+		return false
+	}
+	if iface, ok := c.expected.Underlying().(*types.Interface); ok && iface.Empty() {
+		// Anything is assignable to any.
+		return false
+	}
+	var t types.Type
+	switch obj := obj.(type) {
+	case *types.Var, *types.Const:
+		t = obj.Type()
+	case *types.Func:
+		res := obj.Type().(*types.Signature).Results()
+		if res.Len() != 1 {
+			return false
+		}
+		t = res.At(0).Type()
+	default:
+		return false
+	}
+	// Invalid types are assignable to anything.
+	return t != types.Typ[types.Invalid] && types.AssignableTo(t, c.expected)
+}
+
+// objectItem returns the completion item of obj, named name in scope.
+func (c *completer) objectItem(obj types.Object, name string) protocol.CompletionItem {
+	item := protocol.CompletionItem{
+		Label:      name,
+		InsertText: name,
+	}
+	switch obj := obj.(type) {
+	case *types.Var:
+		item.Kind = protocol.CompletionItemKindVariable
+		if obj.IsField() {
+			item.Kind = protocol.CompletionItemKindField
+		}
+		item.Detail = types.TypeString(obj.Type(), c.qualifier)
+	case *types.Const:
+		item.Kind = protocol.CompletionItemKindConstant
+		item.Detail = types.TypeString(obj.Type(), c.qualifier)
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		item.Kind = protocol.CompletionItemKindFunction
+		item.InsertText, item.InsertTextFormat = c.s.callInsertText(name, sig, c.pkg)
+		item.Detail = "func " + signatureInformation(name, sig, c.pkg).Label
+	case *types.TypeName:
+		item.Kind = protocol.CompletionItemKindClass
+		switch obj.Type().Underlying().(type) {
+		case *types.Struct:
+			item.Kind = protocol.CompletionItemKindStruct
+			item.Detail = "struct{...}"
+		case *types.Interface:
+			item.Kind = protocol.CompletionItemKindInterface
+			item.Detail = "interface{...}"
+		default:
+			item.Detail = types.TypeString(obj.Type().Underlying(), c.qualifier)
+		}
+	case *types.PkgName:
+		item.Kind = protocol.CompletionItemKindModule
+		item.Detail = "package " + obj.Imported().Path()
+		return item
+	}
+	// Package-level objects are documented on resolve.
+	if obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() {
+		item.Data = &completionData{Pkg: obj.Pkg().Path(), Name: obj.Name()}
+	}
+	return item
+}
+
+// compositeLitFields adds the fields of the struct literal enclosing c.pos
+// which aren't set yet, if c.pos is at the position of a key.
+func (c *completer) compositeLitFields(path []ast.Node) {
+	var lit *ast.CompositeLit
+	for i, n := range path {
+		if kv, ok := n.(*ast.KeyValueExpr); ok && c.pos > kv.Colon {
+			// The value of a field.
+			return
+		}
+		if l, ok := n.(*ast.CompositeLit); ok {
+			if i > 1 || l.Lbrace >= c.pos || c.pos > l.Rbrace {
+				// Not an element of the literal, e.g. in a nested call.
+				return
+			}
+			lit = l
+			break
+		}
+	}
+	if lit == nil {
+		return
+	}
+	st, ok := underlying(c.info.TypeOf(lit)).(*types.Struct)
+	if !ok {
+		return
+	}
+
+	set := map[string]bool{}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if id, ok := kv.Key.(*ast.Ident); ok {
+				set[id.Name] = true
+			}
+		} else if elt.Pos() > c.pos || c.pos > elt.End() {
+			// Positional fields can't be mixed with keyed fields.
+			return
+		}
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if set[field.Name()] || !(field.Exported() || field.Pkg() == c.pkg) {
+			continue
+		}
+		item := protocol.CompletionItem{
+			Label:      field.Name(),
+			InsertText: field.Name() + ": ",
+			Kind:       protocol.CompletionItemKindField,
+			Detail:     types.TypeString(field.Type(), c.qualifier),
+		}
+		if data := memberData(c.info.TypeOf(lit), field.Name()); data != nil {
+			item.Data = data
+		}
+		c.add(item, exportedScore(field.Name(), scoreContext))
+	}
+}
+
+// switchConstants adds the constants of the type of the tag of the switch
+// statement, if c.pos is in the expressions of one of its case clauses.
+// The constants already used by the other clauses are skipped.
+func (c *completer) switchConstants(path []ast.Node) {
+	var clause *ast.CaseClause
+	var sw *ast.SwitchStmt
+	for i, n := range path {
+		if cc, ok := n.(*ast.CaseClause); ok {
+			if cc.List == nil || c.pos > cc.Colon {
+				// A default clause, or in the body of the clause.
+				return
+			}
+			if i+2 < len(path) {
+				sw, _ = path[i+2].(*ast.SwitchStmt)
+			}
+			clause = cc
+			break
+		}
+	}
+	if clause == nil || sw == nil || sw.Tag == nil {
+		return
+	}
+	named, ok := c.info.TypeOf(sw.Tag).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return
+	}
+
+	used := map[types.Object]bool{}
+	for _, stmt := range sw.Body.List {
+		for _, e := range stmt.(*ast.CaseClause).List {
+			ast.Inspect(e, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					used[c.info.Uses[id]] = true
+				}
+				return true
+			})
+		}
+	}
+
+	scope := named.Obj().Pkg().Scope()
+	for _, name := range scope.Names() {
+		cst, ok := scope.Lookup(name).(*types.Const)
+		if !ok || used[cst] || !types.Identical(cst.Type(), named) {
+			continue
+		}
+		if cst.Pkg() != c.pkg && !cst.Exported() {
+			continue
+		}
+		label := name
+		if q := c.qualifier(cst.Pkg()); q != "" {
+			label = q + "." + name
+		}
+		c.add(protocol.CompletionItem{
+			Label:      label,
+			InsertText: label,
+			Kind:       protocol.CompletionItemKindEnumMember,
+			Detail:     types.TypeString(named, c.qualifier),
+		}, scoreContext)
+	}
+}
+
+// expectedType returns the type expected at pos, the innermost node of path
+// being the one enclosing pos, or nil if unknown.
+func expectedType(info *types.Info, path []ast.Node, pos token.Pos) types.Type {
+	for i, n := range path {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for j, rhs := range n.Rhs {
+				if rhs.Pos() <= pos && pos <= rhs.End() && len(n.Lhs) == len(n.Rhs) {
+					return info.TypeOf(n.Lhs[j])
+				}
+			}
+			return nil
+		case *ast.ValueSpec:
+			if n.Type != nil && pos > n.Type.End() {
+				return info.TypeOf(n.Type)
+			}
+			return nil
+		case *ast.CallExpr:
+			if pos <= n.Lparen || n.Rparen < pos {
+				continue
+			}
+			sig, ok := info.TypeOf(n.Fun).(*types.Signature)
+			if !ok || sig.Params().Len() == 0 {
+				return nil
+			}
+			arg := activeParameter(n, pos)
+			params := sig.Params()
+			if sig.Variadic() && arg >= params.Len()-1 {
+				return params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+			}
+			if arg < params.Len() {
+				return params.At(arg).Type()
+			}
+			return nil
+		case *ast.ReturnStmt:
+			sig := enclosingSignature(info, path[i+1:])
+			if sig == nil {
+				return nil
+			}
+			if len(n.Results) == 0 && sig.Results().Len() > 0 {
+				return sig.Results().At(0).Type()
+			}
+			for j, res := range n.Results {
+				if res.Pos() <= pos && pos <= res.End() && j < sig.Results().Len() {
+					return sig.Results().At(j).Type()
+				}
+			}
+			return nil
+		case *ast.KeyValueExpr:
+			if pos <= n.Colon || i+1 >= len(path) {
+				return nil
+			}
+			lit, ok := path[i+1].(*ast.CompositeLit)
+			if !ok {
+				return nil
+			}
+			switch t := underlying(info.TypeOf(lit)).(type) {
+			case *types.Struct:
+				if id, ok := n.Key.(*ast.Ident); ok {
+					if obj, ok := info.Uses[id].(*types.Var); ok {
+						return obj.Type()
+					}
+				}
+			case *types.Map:
+				return t.Elem()
+			}
+			return nil
+		case *ast.CompositeLit:
+			switch t := underlying(info.TypeOf(n)).(type) {
+			case *types.Slice:
+				return t.Elem()
+			case *types.Array:
+				return t.Elem()
+			}
+			return nil
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				return types.Typ[types.Bool]
+			}
+			if pos > n.OpPos {
+				return info.TypeOf(n.X)
+			}
+			return info.TypeOf(n.Y)
+		case *ast.CaseClause:
+			if i+2 < len(path) {
+				if sw, ok := path[i+2].(*ast.SwitchStmt); ok && sw.Tag != nil {
+					return info.TypeOf(sw.Tag)
+				}
+			}
+			return nil
+		case ast.Stmt, ast.Decl:
+			return nil
+		}
+	}
+	return nil
+}
+
+// enclosingSignature returns the signature of the innermost function of
+// path.
+func enclosingSignature(info *types.Info, path []ast.Node) *types.Signature {
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if obj, ok := info.Defs[n.Name].(*types.Func); ok {
+				return obj.Type().(*types.Signature)
+			}
+			return nil
+		case *ast.FuncLit:
+			sig, _ := info.TypeOf(n).(*types.Signature)
+			return sig
+		}
+	}
+	return nil
+}
+
+// bareReturnBefore returns the return statement without results ending
+// before pos on the same line, in the block path[0].
+func bareReturnBefore(tokFile *token.File, path []ast.Node, pos token.Pos) *ast.ReturnStmt {
+	var list []ast.Stmt
+	switch n := path[0].(type) {
+	case *ast.BlockStmt:
+		list = n.List
+	case *ast.CaseClause:
+		list = n.Body
+	}
+	for _, stmt := range list {
+		ret, ok := stmt.(*ast.ReturnStmt)
+		if ok && len(ret.Results) == 0 && ret.End() <= pos && tokFile.Line(ret.End()) == tokFile.Line(pos) {
+			return ret
+		}
+	}
+	return nil
+}
+
+// isStatementStart reports whether pos is where a statement starts, e.g. at
+// the start of the line in a block, possibly after the beginning of an
+// identifier.
+func isStatementStart(path []ast.Node, pos token.Pos) bool {
+	switch n := path[0].(type) {
+	case *ast.Ident:
+		_, ok := path[1].(*ast.ExprStmt)
+		return ok && n.Pos() == pos
+	case *ast.BlockStmt:
+		return n.Lbrace < pos
+	case *ast.CaseClause:
+		return n.Colon < pos
+	}
+	return false
+}
+
+// underlying returns the underlying type of t, or of the element type of t
+// if it's a pointer. It returns nil if t is nil.
+func underlying(t types.Type) types.Type {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if t == nil {
+		return nil
+	}
+	return t.Underlying()
+}
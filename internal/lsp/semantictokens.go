@@ -0,0 +1,365 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gnolang/gno/gnovm/pkg/gnolang"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+// semanticTokenTypes are the token types of the legend, the index of a type
+// is its value in the encoded tokens. Constants are variables with the
+// readonly modifier.
+var semanticTokenTypes = []protocol.SemanticTokenTypes{
+	protocol.SemanticTokenNamespace,
+	protocol.SemanticTokenType,
+	protocol.SemanticTokenTypeParameter,
+	protocol.SemanticTokenParameter,
+	protocol.SemanticTokenVariable,
+	protocol.SemanticTokenFunction,
+	protocol.SemanticTokenMethod,
+	protocol.SemanticTokenProperty,
+}
+
+// semanticTokenModifierPersistent is the modifier of the package-level
+// variables of realms, whose values are persisted between transactions.
+const semanticTokenModifierPersistent protocol.SemanticTokenModifiers = "persistent"
+
+// semanticTokenModifiers are the token modifiers of the legend, the index of
+// a modifier is its bit in the encoded tokens.
+var semanticTokenModifiers = []protocol.SemanticTokenModifiers{
+	protocol.SemanticTokenModifierReadonly,
+	protocol.SemanticTokenModifierDefaultLibrary,
+	protocol.SemanticTokenModifierDeprecated,
+	semanticTokenModifierPersistent,
+}
+
+// semanticTokensOptions are the options of the semantic tokens provider,
+// missing from protocol.SemanticTokensOptions.
+type semanticTokensOptions struct {
+	Legend protocol.SemanticTokensLegend `json:"legend"`
+	Range  bool                          `json:"range"`
+	Full   semanticTokensFullOptions     `json:"full"`
+}
+
+type semanticTokensFullOptions struct {
+	Delta bool `json:"delta"`
+}
+
+func (s *server) SemanticTokensFull(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.SemanticTokensParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	data, err := s.semanticTokens(ctx, snapshot, file, nil)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, s.storeSemanticTokens(uri.Filename(), data), nil)
+}
+
+func (s *server) SemanticTokensFullDelta(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.SemanticTokensDeltaParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	data, err := s.semanticTokens(ctx, snapshot, file, nil)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	prev := s.loadSemanticTokens(uri.Filename())
+	tokens := s.storeSemanticTokens(uri.Filename(), data)
+	if prev == nil || prev.ResultID != params.PreviousResultID {
+		// Unknown previous result, send all the tokens.
+		return reply(ctx, tokens, nil)
+	}
+	return reply(ctx, protocol.SemanticTokensDelta{
+		ResultID: tokens.ResultID,
+		Edits:    semanticTokensEdits(prev.Data, tokens.Data),
+	}, nil)
+}
+
+func (s *server) SemanticTokensRange(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.SemanticTokensRangeParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	data, err := s.semanticTokens(ctx, snapshot, file, &params.Range)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, protocol.SemanticTokens{Data: data}, nil)
+}
+
+// loadSemanticTokens returns the last tokens sent for filename, or nil.
+func (s *server) loadSemanticTokens(filename string) *protocol.SemanticTokens {
+	s.semanticTokensMu.Lock()
+	defer s.semanticTokensMu.Unlock()
+	return s.semanticTokensResults[filename]
+}
+
+// storeSemanticTokens records data as the last tokens sent for filename,
+// under a new result id, so the next request can ask for a delta.
+func (s *server) storeSemanticTokens(filename string, data []uint32) *protocol.SemanticTokens {
+	s.semanticTokensMu.Lock()
+	defer s.semanticTokensMu.Unlock()
+	s.semanticTokensID++
+	tokens := &protocol.SemanticTokens{
+		ResultID: strconv.Itoa(s.semanticTokensID),
+		Data:     data,
+	}
+	s.semanticTokensResults[filename] = tokens
+	return tokens
+}
+
+// semanticTokensEdits returns the edit turning prev into data, replacing
+// what's between their common prefix and suffix.
+func semanticTokensEdits(prev, data []uint32) []protocol.SemanticTokensEdit {
+	start := 0
+	for start < len(prev) && start < len(data) && prev[start] == data[start] {
+		start++
+	}
+	end := 0
+	for end < len(prev)-start && end < len(data)-start &&
+		prev[len(prev)-1-end] == data[len(data)-1-end] {
+		end++
+	}
+	if start == len(prev) && start == len(data) {
+		return []protocol.SemanticTokensEdit{}
+	}
+	return []protocol.SemanticTokensEdit{{
+		Start:       uint32(start),
+		DeleteCount: uint32(len(prev) - start - end),
+		Data:        data[start : len(data)-end],
+	}}
+}
+
+// semanticToken is an identifier of a file, with its index in
+// semanticTokenTypes and the bits of its modifiers. Its position and length
+// are expressed in UTF-16 code units.
+type semanticToken struct {
+	pos       protocol.Position
+	length    uint32
+	typ       int
+	modifiers int
+}
+
+// semanticTokens returns the encoded tokens of the identifiers of file, or
+// only the ones in rng if not nil.
+func (s *server) semanticTokens(ctx context.Context, snapshot *Snapshot, file *GnoFile, rng *protocol.Range) ([]uint32, error) {
+	tc, unit, f, err := s.checkFile(ctx, snapshot, file)
+	if err != nil {
+		return nil, err
+	}
+
+	// The parameters and results of the functions are declared by their
+	// signatures.
+	params := map[types.Object]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		var lists []*ast.FieldList
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			lists = append(lists, n.Recv)
+		case *ast.FuncType:
+			lists = append(lists, n.Params, n.Results)
+		default:
+			return true
+		}
+		for _, list := range lists {
+			if list == nil {
+				continue
+			}
+			for _, field := range list.List {
+				for _, name := range field.Names {
+					if obj := unit.info.Defs[name]; obj != nil {
+						params[obj] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	deprecated := map[types.Object]bool{}
+	isDeprecated := func(obj types.Object) bool {
+		res, ok := deprecated[obj]
+		if !ok {
+			res = isDeprecatedDoc(objectDoc(tc, obj))
+			deprecated[obj] = res
+		}
+		return res
+	}
+
+	var tokens []semanticToken
+	ast.Inspect(f, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := unit.info.Defs[id]
+		if obj == nil {
+			obj = unit.info.Uses[id]
+		}
+		if id == f.Name {
+			// The package clause has no object.
+			obj = types.NewPkgName(f.Package, unit.pkg, id.Name, unit.pkg)
+		}
+		if obj == nil {
+			return true
+		}
+		typ, modifiers, ok := semanticTokenOf(obj, params[obj])
+		if !ok {
+			return true
+		}
+		if obj.Pos().IsValid() && isDeprecated(obj) {
+			modifiers |= semanticTokenModifier(protocol.SemanticTokenModifierDeprecated)
+		}
+		tokens = append(tokens, semanticToken{
+			pos:       lspPosition(tc.fset.Position(id.Pos()), tc.overlay),
+			length:    utf16Len([]byte(id.Name)),
+			typ:       typ,
+			modifiers: modifiers,
+		})
+		return true
+	})
+
+	slices.SortFunc(tokens, func(a, b semanticToken) int {
+		if a.pos.Line != b.pos.Line {
+			return int(a.pos.Line) - int(b.pos.Line)
+		}
+		return int(a.pos.Character) - int(b.pos.Character)
+	})
+	return encodeSemanticTokens(tokens, rng), nil
+}
+
+// semanticTokenOf returns the type and the modifiers of the identifiers
+// denoting obj, and false if they aren't highlighted, like labels.
+func semanticTokenOf(obj types.Object, param bool) (typ, modifiers int, ok bool) {
+	var t protocol.SemanticTokenTypes
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		t = protocol.SemanticTokenNamespace
+	case *types.TypeName:
+		t = protocol.SemanticTokenType
+		if _, ok := obj.Type().(*types.TypeParam); ok {
+			t = protocol.SemanticTokenTypeParameter
+		}
+	case *types.Var:
+		switch {
+		case obj.IsField():
+			t = protocol.SemanticTokenProperty
+		case param:
+			t = protocol.SemanticTokenParameter
+		default:
+			t = protocol.SemanticTokenVariable
+			if obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() && gnolang.IsRealmPath(obj.Pkg().Path()) {
+				modifiers |= semanticTokenModifier(semanticTokenModifierPersistent)
+			}
+		}
+	case *types.Const, *types.Nil:
+		t = protocol.SemanticTokenVariable
+		modifiers |= semanticTokenModifier(protocol.SemanticTokenModifierReadonly)
+	case *types.Func:
+		t = protocol.SemanticTokenFunction
+		if obj.Type().(*types.Signature).Recv() != nil {
+			t = protocol.SemanticTokenMethod
+		}
+	case *types.Builtin:
+		t = protocol.SemanticTokenFunction
+	default:
+		return 0, 0, false
+	}
+	pkg := obj.Pkg()
+	if pn, ok := obj.(*types.PkgName); ok {
+		pkg = pn.Imported()
+	}
+	if pkg == nil || isStdlib(pkg.Path()) {
+		modifiers |= semanticTokenModifier(protocol.SemanticTokenModifierDefaultLibrary)
+	}
+	return slices.Index(semanticTokenTypes, t), modifiers, true
+}
+
+// semanticTokenModifier returns the bit of modifier.
+func semanticTokenModifier(modifier protocol.SemanticTokenModifiers) int {
+	return 1 << slices.Index(semanticTokenModifiers, modifier)
+}
+
+// isStdlib reports whether path is the import path of a standard library,
+// whose first element has no dot, unlike gno.land/p/demo/avl.
+func isStdlib(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return path != "" && !strings.Contains(first, ".")
+}
+
+// isDeprecatedDoc reports whether doc has a paragraph starting with
+// "Deprecated: ".
+func isDeprecatedDoc(doc string) bool {
+	for _, par := range strings.Split(doc, "\n\n") {
+		if strings.HasPrefix(strings.TrimSpace(par), "Deprecated: ") {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeSemanticTokens returns tokens, sorted by position, encoded relative
+// to each other. Only the tokens in rng are encoded if it's not nil.
+func encodeSemanticTokens(tokens []semanticToken, rng *protocol.Range) []uint32 {
+	data := []uint32{}
+	var line, char uint32
+	for _, tok := range tokens {
+		if rng != nil && !inRange(*rng, tok.pos) {
+			continue
+		}
+		deltaChar := tok.pos.Character
+		if tok.pos.Line == line {
+			deltaChar -= char
+		}
+		data = append(data,
+			tok.pos.Line-line, deltaChar, tok.length,
+			uint32(tok.typ), uint32(tok.modifiers))
+		line, char = tok.pos.Line, tok.pos.Character
+	}
+	return data
+}
+
+// inRange reports whether pos is in rng, both expressed in UTF-16 code
+// units.
+func inRange(rng protocol.Range, pos protocol.Position) bool {
+	before := func(a, b protocol.Position) bool {
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	}
+	return !before(pos, rng.Start) && before(pos, rng.End)
+}
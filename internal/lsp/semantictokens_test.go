@@ -0,0 +1,47 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSemanticTokensUTF16(t *testing.T) {
+	s, dir := newTestServer(t, map[string]string{
+		"gno.mod": "module gno.land/r/test/st\n",
+		"st.gno":  "package st\n\nvar é, s = 1, \"😀\"; var x = s\n",
+	})
+	file := testFile(t, s, dir, "st.gno")
+	data, err := s.semanticTokens(context.Background(), s.getSnapshot(), file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The line, start character and length of the tokens, decoded from
+	// their deltas.
+	type token struct{ line, char, length uint32 }
+	var got []token
+	var line, char uint32
+	for i := 0; i+4 < len(data); i += 5 {
+		if data[i] > 0 {
+			char = 0
+		}
+		line += data[i]
+		char += data[i+1]
+		got = append(got, token{line, char, data[i+2]})
+	}
+	want := []token{
+		{0, 8, 2},  // st
+		{2, 4, 1},  // é
+		{2, 7, 1},  // s
+		{2, 24, 1}, // x, after the surrogate pair of 😀
+		{2, 28, 1}, // s
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got tokens %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got tokens %v, want %v", got, want)
+			break
+		}
+	}
+}
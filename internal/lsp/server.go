@@ -6,9 +6,12 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 
 	"go.lsp.dev/jsonrpc2"
 	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
 
 	"github.com/gnolang/gnopls/internal/env"
 	"github.com/gnolang/gnopls/internal/tools"
@@ -19,34 +22,117 @@ type server struct {
 	conn jsonrpc2.Conn
 	env  *env.Env
 
+	// snapshot holds the open files, guarded by snapshotMu. It is replaced
+	// on each modification.
+	snapshotMu      sync.Mutex
 	snapshot        *Snapshot
 	completionStore *CompletionStore
+	symbolIndex     *SymbolIndex
 	cache           *Cache
 
+	// diagnosticsCancel cancels the pending live diagnostics by package
+	// directory, guarded by diagnosticsMu.
+	diagnosticsMu     sync.Mutex
+	diagnosticsCancel map[string]*diagnosticsRun
+
+	// semanticTokensResults holds the last semantic tokens sent by file,
+	// under the result ids counted by semanticTokensID, guarded by
+	// semanticTokensMu.
+	semanticTokensMu      sync.Mutex
+	semanticTokensID      int
+	semanticTokensResults map[string]*protocol.SemanticTokens
+
+	workspaceFolders []string
+	// workspaceIndex indexes the packages of the workspace folders, guarded
+	// by workspaceMu. It's nil until built, or once invalidated.
+	workspaceMu    sync.Mutex
+	workspaceIndex *workspaceIndex
+	// typeIndex indexes the named types of the packages for the
+	// implementation requests, guarded by typeIndexMu, which is held while
+	// a request uses it.
+	typeIndexMu sync.Mutex
+	typeIndex   *typeIndex
+
+	// snippets is true if the client supports snippets in completion items.
+	snippets bool
+	// resolveImports is true if the client resolves the additional text
+	// edits of completion items, so the imports they add are resolved lazily.
+	resolveImports bool
+	settings       settings
+
 	formatOpt tools.FormattingOption
 }
 
+// settings are the settings of the server, sent by the client in the
+// initialization options.
+type settings struct {
+	// CompletionBudget is the maximum number of completion items.
+	CompletionBudget int `json:"completionBudget"`
+	// InlayHints enables the categories of inlay hints.
+	InlayHints inlayHintSettings `json:"inlayHints"`
+}
+
+// serverCapabilities is protocol.ServerCapabilities, with the capabilities
+// missing from it.
+type serverCapabilities struct {
+	protocol.ServerCapabilities
+	InlayHintProvider bool `json:"inlayHintProvider,omitempty"`
+}
+
+// initializeResult is protocol.InitializeResult, with serverCapabilities.
+type initializeResult struct {
+	Capabilities serverCapabilities   `json:"capabilities"`
+	ServerInfo   *protocol.ServerInfo `json:"serverInfo,omitempty"`
+}
+
 func BuildServerHandler(conn jsonrpc2.Conn, e *env.Env) jsonrpc2.Handler {
 	dirs := []string{}
 	if e.GNOROOT != "" {
 		dirs = append(dirs, filepath.Join(e.GNOROOT, "examples"))
 		dirs = append(dirs, filepath.Join(e.GNOROOT, "gnovm/stdlibs"))
 	}
+	if e.GNOHOME != "" { // downloaded packages
+		dirs = append(dirs, filepath.Join(e.GNOHOME, "pkg", "mod"))
+	}
+	completionStore := InitCompletionStore(dirs)
 	server := &server{
 		conn: conn,
 
 		env: e,
 
 		snapshot:        NewSnapshot(),
-		completionStore: InitCompletionStore(dirs),
+		completionStore: completionStore,
+		symbolIndex:     NewSymbolIndex(completionStore.pkgs, nil),
 		cache:           NewCache(),
 
+		diagnosticsCancel:     map[string]*diagnosticsRun{},
+		semanticTokensResults: map[string]*protocol.SemanticTokens{},
+
 		formatOpt: tools.Gofumpt,
 	}
 	env.GlobalEnv = e
 	return jsonrpc2.ReplyHandler(server.ServerHandler)
 }
 
+// getSnapshot returns the current snapshot.
+func (s *server) getSnapshot() *Snapshot {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	return s.snapshot
+}
+
+// updateSnapshot replaces the current snapshot by the result of update.
+func (s *server) updateSnapshot(update func(*Snapshot) (*Snapshot, error)) (*Snapshot, error) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	snapshot, err := update(s.snapshot)
+	if err != nil {
+		return nil, err
+	}
+	s.snapshot = snapshot
+	return snapshot, nil
+}
+
 func (s *server) ServerHandler(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
 	switch req.Method() {
 	case "exit":
@@ -71,8 +157,42 @@ func (s *server) ServerHandler(ctx context.Context, reply jsonrpc2.Replier, req
 		return s.Hover(ctx, reply, req)
 	case "textDocument/completion":
 		return s.Completion(ctx, reply, req)
+	case "completionItem/resolve":
+		return s.CompletionResolve(ctx, reply, req)
 	case "textDocument/definition":
 		return s.Definition(ctx, reply, req)
+	case "textDocument/typeDefinition":
+		return s.TypeDefinition(ctx, reply, req)
+	case "textDocument/implementation":
+		return s.Implementation(ctx, reply, req)
+	case "textDocument/prepareCallHierarchy":
+		return s.PrepareCallHierarchy(ctx, reply, req)
+	case "callHierarchy/incomingCalls":
+		return s.IncomingCalls(ctx, reply, req)
+	case "callHierarchy/outgoingCalls":
+		return s.OutgoingCalls(ctx, reply, req)
+	case "textDocument/references":
+		return s.References(ctx, reply, req)
+	case "textDocument/prepareRename":
+		return s.PrepareRename(ctx, reply, req)
+	case "textDocument/rename":
+		return s.Rename(ctx, reply, req)
+	case "textDocument/documentSymbol":
+		return s.DocumentSymbol(ctx, reply, req)
+	case "textDocument/signatureHelp":
+		return s.SignatureHelp(ctx, reply, req)
+	case "textDocument/codeAction":
+		return s.CodeAction(ctx, reply, req)
+	case "textDocument/semanticTokens/full":
+		return s.SemanticTokensFull(ctx, reply, req)
+	case "textDocument/semanticTokens/full/delta":
+		return s.SemanticTokensFullDelta(ctx, reply, req)
+	case "textDocument/semanticTokens/range":
+		return s.SemanticTokensRange(ctx, reply, req)
+	case "textDocument/inlayHint":
+		return s.InlayHint(ctx, reply, req)
+	case "workspace/symbol":
+		return s.WorkspaceSymbol(ctx, reply, req)
 	default:
 		return jsonrpc2.MethodNotFoundHandler(ctx, reply, req)
 	}
@@ -84,31 +204,80 @@ func (s *server) Initialize(ctx context.Context, reply jsonrpc2.Replier, req jso
 		return sendParseError(ctx, reply, err)
 	}
 
-	return reply(ctx, protocol.InitializeResult{
+	for _, folder := range params.WorkspaceFolders {
+		s.workspaceFolders = append(s.workspaceFolders, uri.URI(folder.URI).Filename())
+	}
+	if len(s.workspaceFolders) == 0 && params.RootURI != "" {
+		s.workspaceFolders = append(s.workspaceFolders, params.RootURI.Filename())
+	}
+	if td := params.Capabilities.TextDocument; td != nil && td.Completion != nil && td.Completion.CompletionItem != nil {
+		s.snippets = td.Completion.CompletionItem.SnippetSupport
+		if rs := td.Completion.CompletionItem.ResolveSupport; rs != nil {
+			s.resolveImports = slices.Contains(rs.Properties, "additionalTextEdits")
+		}
+	}
+	if params.InitializationOptions != nil {
+		// Decode the options, which were unmarshaled as a map.
+		b, _ := json.Marshal(params.InitializationOptions)
+		if err := json.Unmarshal(b, &s.settings); err != nil {
+			slog.Error("initialization options", "error", err)
+		}
+	}
+
+	return reply(ctx, initializeResult{
 		ServerInfo: &protocol.ServerInfo{
 			Name:    "gnopls",
 			Version: version.GetVersion(ctx),
 		},
-		Capabilities: protocol.ServerCapabilities{
-			TextDocumentSync: protocol.TextDocumentSyncOptions{
-				Change:    protocol.TextDocumentSyncKindFull,
-				OpenClose: true,
-				Save: &protocol.SaveOptions{
-					IncludeText: true,
+		Capabilities: serverCapabilities{
+			ServerCapabilities: protocol.ServerCapabilities{
+				TextDocumentSync: protocol.TextDocumentSyncOptions{
+					Change:    protocol.TextDocumentSyncKindIncremental,
+					OpenClose: true,
+					Save: &protocol.SaveOptions{
+						IncludeText: true,
+					},
 				},
-			},
-			CompletionProvider: &protocol.CompletionOptions{
-				TriggerCharacters: []string{"."},
-				ResolveProvider:   false,
-			},
-			HoverProvider: true,
-			ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
-				Commands: []string{
-					"gnopls.version",
+				CompletionProvider: &protocol.CompletionOptions{
+					TriggerCharacters: []string{".", "\"", "/"},
+					ResolveProvider:   true,
+				},
+				HoverProvider: true,
+				ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
+					Commands: []string{
+						"gnopls.version",
+					},
+				},
+				DefinitionProvider:         true,
+				TypeDefinitionProvider:     true,
+				ImplementationProvider:     true,
+				ReferencesProvider:         true,
+				CallHierarchyProvider:      true,
+				DocumentFormattingProvider: true,
+				DocumentSymbolProvider:     true,
+				WorkspaceSymbolProvider:    true,
+				RenameProvider: &protocol.RenameOptions{
+					PrepareProvider: true,
+				},
+				SignatureHelpProvider: &protocol.SignatureHelpOptions{
+					TriggerCharacters: []string{"(", ","},
+				},
+				CodeActionProvider: &protocol.CodeActionOptions{
+					CodeActionKinds: []protocol.CodeActionKind{
+						protocol.QuickFix,
+						protocol.SourceOrganizeImports,
+					},
+				},
+				SemanticTokensProvider: semanticTokensOptions{
+					Legend: protocol.SemanticTokensLegend{
+						TokenTypes:     semanticTokenTypes,
+						TokenModifiers: semanticTokenModifiers,
+					},
+					Range: true,
+					Full:  semanticTokensFullOptions{Delta: true},
 				},
 			},
-			DefinitionProvider:         true,
-			DocumentFormattingProvider: true,
+			InlayHintProvider: true,
 		},
 	}, nil)
 }
@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gnolang/gnopls/internal/env"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+// newTestServer returns a server whose workspace is a temporary directory
+// holding files, by path relative to it. The gno files are open in the
+// snapshot of the server. No GNOROOT is set, so the fixtures can't import
+// the standard libraries.
+func newTestServer(t *testing.T, files map[string]string) (*server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	env.GlobalEnv = &env.Env{}
+	s := &server{
+		env:                   env.GlobalEnv,
+		snapshot:              NewSnapshot(),
+		completionStore:       &CompletionStore{},
+		symbolIndex:           NewSymbolIndex(nil, nil),
+		cache:                 NewCache(),
+		diagnosticsCancel:     map[string]*diagnosticsRun{},
+		semanticTokensResults: map[string]*protocol.SemanticTokens{},
+		workspaceFolders:      []string{dir},
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(name, ".gno") {
+			s.snapshot = s.snapshot.WithFile(&GnoFile{URI: getURI(filename), Src: []byte(content), Version: 1})
+		}
+	}
+	return s, dir
+}
+
+// testFile returns the file of the snapshot of s located at name in dir.
+func testFile(t *testing.T, s *server, dir, name string) *GnoFile {
+	t.Helper()
+	file, ok := s.getSnapshot().Get(filepath.Join(dir, name))
+	if !ok {
+		t.Fatalf("%s not found in snapshot", name)
+	}
+	return file
+}
+
+// testCall sends a request of the given method to handler, and decodes
+// its result into res.
+func testCall(t *testing.T, handler func(context.Context, jsonrpc2.Replier, jsonrpc2.Request) error, method string, params, res any) {
+	t.Helper()
+	req, err := jsonrpc2.NewCall(jsonrpc2.NewNumberID(1), method, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := func(ctx context.Context, result any, err error) error {
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return json.Unmarshal(b, res)
+	}
+	if err := handler(context.Background(), reply, req); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,321 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/gnolang/gnopls/internal/builtin"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func (s *server) SignatureHelp(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.SignatureHelpParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	snapshot := s.getSnapshot()
+	file, ok := snapshot.Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+
+	help, err := s.signatureHelp(ctx, snapshot, file, params.Position)
+	if err != nil {
+		return reply(ctx, nil, err)
+	}
+	return reply(ctx, help, nil)
+}
+
+// signatureHelp returns the signature of the call enclosing pos in file, or
+// nil if pos isn't inside the parentheses of a call.
+func (s *server) signatureHelp(ctx context.Context, snapshot *Snapshot, file *GnoFile, pos protocol.Position) (*protocol.SignatureHelp, error) {
+	tc, unit, f, err := s.checkFile(ctx, snapshot, file)
+	if err != nil {
+		return nil, err
+	}
+	tokFile := tc.fset.File(f.Pos())
+	offset := file.PositionToOffset(pos)
+	if offset < 0 || offset > tokFile.Size() {
+		return nil, errors.New("position out of range")
+	}
+
+	call := enclosingCall(f, tokFile.Pos(offset))
+	if call == nil {
+		return nil, nil
+	}
+
+	var sigInfo *protocol.SignatureInformation
+	fun := ast.Unparen(call.Fun)
+	if obj := calleeObject(unit.info, fun); obj != nil {
+		if b, ok := obj.(*types.Builtin); ok {
+			sigInfo = builtinSignature(b.Name())
+		} else if sig := calleeSignature(unit.info, fun, obj); sig != nil {
+			sigInfo = signatureInformation(obj.Name(), sig, unit.pkg)
+			if doc := objectDoc(tc, obj); doc != "" {
+				sigInfo.Documentation = protocol.MarkupContent{
+					Kind:  protocol.Markdown,
+					Value: doc,
+				}
+			}
+		}
+	} else if tv, ok := unit.info.Types[fun]; ok && !tv.IsType() {
+		// Function values, like func literals or results of other calls.
+		if sig, ok := tv.Type.Underlying().(*types.Signature); ok {
+			sigInfo = signatureInformation("func", sig, unit.pkg)
+		}
+	}
+	if sigInfo == nil {
+		return nil, nil
+	}
+
+	active := activeParameter(call, tokFile.Pos(offset))
+	if n := len(sigInfo.Parameters); n > 0 && active >= n {
+		// Extra arguments of variadic functions, or too many arguments.
+		active = n - 1
+	}
+	return &protocol.SignatureHelp{
+		Signatures:      []protocol.SignatureInformation{*sigInfo},
+		ActiveParameter: uint32(active),
+	}, nil
+}
+
+// unitOf returns the type-check result and the parsed file of filename.
+func unitOf(fset *token.FileSet, units []*TypeCheckResult, filename string) (*TypeCheckResult, *ast.File) {
+	for _, unit := range units {
+		for _, f := range unit.files {
+			if fset.File(f.Pos()).Name() == filename {
+				return unit, f
+			}
+		}
+	}
+	return nil, nil
+}
+
+// enclosingCall returns the innermost call expression whose parentheses
+// enclose pos. It stops at statements, so the call isn't returned when pos
+// is in the body of a function literal passed as argument.
+func enclosingCall(f *ast.File, pos token.Pos) *ast.CallExpr {
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if n.Lparen < pos && pos <= n.Rparen {
+				return n
+			}
+		case ast.Stmt, ast.Decl:
+			// Function literal bodies, or pos outside of any call.
+			return nil
+		}
+	}
+	return nil
+}
+
+// calleeObject returns the object of the function or method called by fun,
+// or nil if fun isn't a (possibly instantiated) named function.
+func calleeObject(info *types.Info, fun ast.Expr) types.Object {
+	switch e := fun.(type) {
+	case *ast.IndexExpr: // explicit instantiation, e.g. `Map[int]`
+		fun = e.X
+	case *ast.IndexListExpr:
+		fun = e.X
+	}
+	var id *ast.Ident
+	switch e := fun.(type) {
+	case *ast.Ident:
+		id = e
+	case *ast.SelectorExpr:
+		id = e.Sel
+	default:
+		return nil
+	}
+	switch obj := info.Uses[id].(type) {
+	case *types.Func, *types.Builtin:
+		return obj
+	}
+	return nil
+}
+
+// calleeSignature returns the signature of the call of obj, instantiated if
+// its type arguments are known.
+func calleeSignature(info *types.Info, fun ast.Expr, obj types.Object) *types.Signature {
+	if tv, ok := info.Types[fun]; ok && tv.Type != nil {
+		if sig, ok := tv.Type.(*types.Signature); ok {
+			return sig
+		}
+	}
+	// The call may not type-check while being written, fallback to the
+	// generic signature.
+	sig, _ := obj.Type().(*types.Signature)
+	return sig
+}
+
+// signatureInformation formats sig, e.g. `Get(key string) (interface{}, bool)`.
+func signatureInformation(name string, sig *types.Signature, pkg *types.Package) *protocol.SignatureInformation {
+	qf := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+
+	var label strings.Builder
+	label.WriteString(name)
+	if tparams := sig.TypeParams(); tparams != nil && tparams.Len() > 0 {
+		label.WriteByte('[')
+		for i := 0; i < tparams.Len(); i++ {
+			if i > 0 {
+				label.WriteString(", ")
+			}
+			tp := tparams.At(i)
+			label.WriteString(tp.Obj().Name() + " " + types.TypeString(tp.Constraint(), qf))
+		}
+		label.WriteByte(']')
+	}
+
+	label.WriteByte('(')
+	params := sig.Params()
+	info := &protocol.SignatureInformation{
+		Parameters: make([]protocol.ParameterInformation, 0, params.Len()),
+	}
+	for i := 0; i < params.Len(); i++ {
+		p := params.At(i)
+		typ := types.TypeString(p.Type(), qf)
+		if sig.Variadic() && i == params.Len()-1 {
+			typ = "..." + types.TypeString(p.Type().(*types.Slice).Elem(), qf)
+		}
+		param := typ
+		if p.Name() != "" {
+			param = p.Name() + " " + typ
+		}
+		if i > 0 {
+			label.WriteString(", ")
+		}
+		label.WriteString(param)
+		info.Parameters = append(info.Parameters, protocol.ParameterInformation{Label: param})
+	}
+	label.WriteByte(')')
+
+	if results := sig.Results(); results.Len() > 0 {
+		label.WriteByte(' ')
+		if results.Len() == 1 && results.At(0).Name() == "" {
+			label.WriteString(types.TypeString(results.At(0).Type(), qf))
+		} else {
+			label.WriteString(types.TypeString(results, qf))
+		}
+	}
+	info.Label = label.String()
+	return info
+}
+
+// builtinSignature returns the signature of the builtin function name, as
+// documented in the builtin package.
+func builtinSignature(name string) *protocol.SignatureInformation {
+	for _, item := range builtin.GetCompletions(name) {
+		if item.Label != name || !strings.HasPrefix(item.Detail, "func ") {
+			continue
+		}
+		label := strings.TrimPrefix(item.Detail, "func ")
+		expr, err := parser.ParseExpr("func" + strings.TrimPrefix(label, name))
+		if err != nil {
+			return nil
+		}
+		ftype, ok := expr.(*ast.FuncType)
+		if !ok {
+			return nil
+		}
+		info := &protocol.SignatureInformation{
+			Label:         label,
+			Documentation: item.Documentation,
+		}
+		for _, field := range ftype.Params.List {
+			typ := types.ExprString(field.Type)
+			if len(field.Names) == 0 {
+				info.Parameters = append(info.Parameters, protocol.ParameterInformation{Label: typ})
+			}
+			for _, n := range field.Names {
+				info.Parameters = append(info.Parameters, protocol.ParameterInformation{Label: n.Name + " " + typ})
+			}
+		}
+		return info
+	}
+	return nil
+}
+
+// activeParameter returns the index of the argument of call at pos.
+func activeParameter(call *ast.CallExpr, pos token.Pos) int {
+	active := 0
+	for i, arg := range call.Args {
+		if pos > arg.End() {
+			active = i + 1
+		}
+	}
+	return active
+}
+
+// objectDoc returns the doc comment of the declaration of obj, found in the
+// files parsed by tc.
+func objectDoc(tc *TypeCheck, obj types.Object) string {
+	f := tc.fileAt(obj.Pos())
+	if f == nil {
+		return ""
+	}
+	var doc *ast.CommentGroup
+	ast.Inspect(f, func(n ast.Node) bool {
+		if doc != nil || n == nil {
+			return false
+		}
+		if n.End() < obj.Pos() || n.Pos() > obj.Pos() {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Name.Pos() == obj.Pos() {
+				doc = n.Doc
+				return false
+			}
+		case *ast.GenDecl:
+			for _, spec := range n.Specs {
+				var specDoc *ast.CommentGroup
+				var names []*ast.Ident
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					specDoc, names = spec.Doc, []*ast.Ident{spec.Name}
+				case *ast.ValueSpec:
+					specDoc, names = spec.Doc, spec.Names
+				}
+				for _, name := range names {
+					if name.Pos() != obj.Pos() {
+						continue
+					}
+					doc = specDoc
+					if doc == nil && len(n.Specs) == 1 {
+						doc = n.Doc
+					}
+					return false
+				}
+			}
+		case *ast.Field:
+			for _, name := range n.Names {
+				if name.Pos() == obj.Pos() {
+					doc = n.Doc
+					if doc == nil {
+						doc = n.Comment
+					}
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return strings.TrimSpace(doc.Text())
+}
@@ -0,0 +1,30 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestSignatureHelp(t *testing.T) {
+	s, dir := newTestServer(t, map[string]string{
+		"gno.mod": "module gno.land/r/test/sig\n",
+		"sig.gno": "package sig\n\n// Add adds a and b.\nfunc Add(a, b int) int { return a + b }\n\nvar _ = Add(1, 2)\n",
+	})
+	file := testFile(t, s, dir, "sig.gno")
+	// After `Add(1, `
+	help, err := s.signatureHelp(context.Background(), s.getSnapshot(), file, protocol.Position{Line: 5, Character: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if help == nil || len(help.Signatures) != 1 {
+		t.Fatalf("got %v, want one signature", help)
+	}
+	if got, want := help.Signatures[0].Label, "Add(a int, b int) int"; got != want {
+		t.Errorf("label = %q, want %q", got, want)
+	}
+	if help.ActiveParameter != 1 {
+		t.Errorf("active parameter = %d, want 1", help.ActiveParameter)
+	}
+}
@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,33 +9,128 @@ import (
 	"go/parser"
 	"go/token"
 	"log/slog"
+	"sort"
 	"strings"
 	"unicode/utf8"
 
 	"go.lsp.dev/protocol"
 	"golang.org/x/mod/modfile"
-
-	cmap "github.com/orcaman/concurrent-map/v2"
 )
 
+// Snapshot is an immutable view of the open files. Opening, changing or
+// closing a file produces a new Snapshot, so a request can safely work with
+// the snapshot it started with while files are edited.
 type Snapshot struct {
-	file cmap.ConcurrentMap[string, *GnoFile]
+	id    uint64
+	files map[string]*GnoFile
 }
 
 func NewSnapshot() *Snapshot {
 	return &Snapshot{
-		file: cmap.New[*GnoFile](),
+		files: map[string]*GnoFile{},
 	}
 }
 
+// ID returns the sequence number of the snapshot, which is incremented by
+// each modification.
+func (s *Snapshot) ID() uint64 {
+	return s.id
+}
+
 func (s *Snapshot) Get(filePath string) (*GnoFile, bool) {
-	return s.file.Get(filePath)
+	f, ok := s.files[filePath]
+	return f, ok
+}
+
+// Files returns the files of s, sorted by path.
+func (s *Snapshot) Files() []*GnoFile {
+	files := make([]*GnoFile, 0, len(s.files))
+	for _, f := range s.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].URI < files[j].URI
+	})
+	return files
 }
 
-// contains gno file.
+// Overlay returns the content of the files of s by file name, to be used
+// instead of the content on disk.
+func (s *Snapshot) Overlay() map[string][]byte {
+	overlay := make(map[string][]byte, len(s.files))
+	for name, f := range s.files {
+		overlay[name] = f.Src
+	}
+	return overlay
+}
+
+// WithFile returns a new snapshot where file replaces the file of the same
+// path.
+func (s *Snapshot) WithFile(file *GnoFile) *Snapshot {
+	return s.clone(func(files map[string]*GnoFile) {
+		files[file.URI.Filename()] = file
+	})
+}
+
+// WithoutFile returns a new snapshot without the file at filePath.
+func (s *Snapshot) WithoutFile(filePath string) *Snapshot {
+	return s.clone(func(files map[string]*GnoFile) {
+		delete(files, filePath)
+	})
+}
+
+func (s *Snapshot) clone(update func(files map[string]*GnoFile)) *Snapshot {
+	files := make(map[string]*GnoFile, len(s.files)+1)
+	for k, v := range s.files {
+		files[k] = v
+	}
+	update(files)
+	return &Snapshot{id: s.id + 1, files: files}
+}
+
+// contains gno file. It must not be modified once added to a Snapshot.
 type GnoFile struct {
 	URI protocol.DocumentURI
 	Src []byte
+	// Version is the LSP document version.
+	Version int32
+}
+
+// ApplyChanges returns a copy of f with changes applied in order, and the
+// new version. A change without range replaces the whole content.
+func (f *GnoFile) ApplyChanges(changes []ContentChange, version int32) (*GnoFile, error) {
+	src := f.Src
+	for _, change := range changes {
+		if change.Range == nil {
+			src = []byte(change.Text)
+			continue
+		}
+		start, err := positionToOffset(src, change.Range.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := positionToOffset(src, change.Range.End)
+		if err != nil {
+			return nil, err
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid range %v", change.Range)
+		}
+		newSrc := make([]byte, 0, len(src)-(end-start)+len(change.Text))
+		newSrc = append(newSrc, src[:start]...)
+		newSrc = append(newSrc, change.Text...)
+		newSrc = append(newSrc, src[end:]...)
+		src = newSrc
+	}
+	return &GnoFile{URI: f.URI, Src: src, Version: version}, nil
+}
+
+// ContentChange is a protocol.TextDocumentContentChangeEvent whose Range is
+// optional, in order to distinguish full content changes from incremental
+// ones.
+type ContentChange struct {
+	Range *protocol.Range `json:"range,omitempty"`
+	Text  string          `json:"text"`
 }
 
 // contains parsed gno file.
@@ -46,11 +142,13 @@ type ParsedGnoFile struct {
 	Src []byte
 }
 
-// ParseGno reads src from disk and parse it
+// ParseGno parses the content of f, which may differ from the file on disk
+// if it has unsaved changes. On syntax errors, the partially parsed file is
+// returned along with the error, if any.
 func (f *GnoFile) ParseGno(ctx context.Context) (*ParsedGnoFile, error) {
 	fset := token.NewFileSet()
-	ast, err := parser.ParseFile(fset, f.URI.Filename(), nil, parser.ParseComments)
-	if err != nil {
+	ast, err := parser.ParseFile(fset, f.URI.Filename(), f.Src, parser.ParseComments)
+	if ast == nil {
 		return nil, err
 	}
 
@@ -62,28 +160,13 @@ func (f *GnoFile) ParseGno(ctx context.Context) (*ParsedGnoFile, error) {
 		Src:  f.Src,
 	}
 
-	return pgf, nil
+	return pgf, err
 }
 
-// ParseGno2 parses src from GnoFile instead of reading from disk
-// Right now it's only used in `completion.go`
-// TODO: Replace content of `ParseGno` with `ParseGno2`
-func (f *GnoFile) ParseGno2(ctx context.Context) (*ParsedGnoFile, error) {
-	fset := token.NewFileSet()
-	ast, err := parser.ParseFile(fset, f.URI.Filename(), f.Src, parser.ParseComments)
-	if err != nil {
-		return nil, err
-	}
-
-	pgf := &ParsedGnoFile{
-		URI: f.URI,
-
-		File: ast,
-		Fset: fset,
-		Src:  f.Src,
-	}
-
-	return pgf, nil
+// overlay returns the content of pgf by filename, to convert its positions
+// to LSP positions.
+func (pgf *ParsedGnoFile) overlay() map[string][]byte {
+	return map[string][]byte{pgf.URI.Filename(): pgf.Src}
 }
 
 // contains parsed gno.mod file.
@@ -135,14 +218,66 @@ func (f *GnoFile) TokenAt(pos protocol.Position) (*HoveredToken, error) {
 	}, nil
 }
 
+// PositionToOffset returns the byte offset of pos in f. Positions out of the
+// file are clamped to its end.
 func (f *GnoFile) PositionToOffset(pos protocol.Position) int {
-	lines := strings.SplitAfter(string(f.Src), "\n")
+	offset, err := positionToOffset(f.Src, pos)
+	if err != nil {
+		return len(f.Src)
+	}
+	return offset
+}
+
+// positionToOffset converts pos, whose character is expressed in UTF-16 code
+// units as required by the LSP, to a byte offset in src.
+func positionToOffset(src []byte, pos protocol.Position) (int, error) {
 	offset := 0
-	for i, l := range lines {
-		if i == int(pos.Line) {
+	for line := uint32(0); line < pos.Line; line++ {
+		i := bytes.IndexByte(src[offset:], '\n')
+		if i < 0 {
+			return 0, fmt.Errorf("line %d out of range", pos.Line)
+		}
+		offset += i + 1
+	}
+	for col := uint32(0); col < pos.Character; {
+		if offset >= len(src) || src[offset] == '\n' {
+			// Be lenient with positions past the end of the line.
 			break
 		}
-		offset += utf8.RuneCountInString(l)
+		r, size := utf8.DecodeRune(src[offset:])
+		offset += size
+		col++
+		if r >= 0x10000 { // encoded as a surrogate pair
+			col++
+		}
+	}
+	return offset, nil
+}
+
+// offsetToPosition converts the byte offset in src to a position whose
+// character is expressed in UTF-16 code units, as required by the LSP. It's
+// the inverse of positionToOffset.
+func offsetToPosition(src []byte, offset int) (protocol.Position, error) {
+	if offset < 0 || offset > len(src) {
+		return protocol.Position{}, fmt.Errorf("offset %d out of range", offset)
+	}
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	return protocol.Position{
+		Line:      uint32(bytes.Count(src[:lineStart], []byte{'\n'})),
+		Character: utf16Len(src[lineStart:offset]),
+	}, nil
+}
+
+// utf16Len returns the number of UTF-16 code units encoding b.
+func utf16Len(b []byte) uint32 {
+	n := uint32(0)
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		n++
+		if r >= 0x10000 { // encoded as a surrogate pair
+			n++
+		}
 	}
-	return offset + int(pos.Character)
+	return n
 }
@@ -0,0 +1,201 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// utf16Src has a 2-byte rune (é, 1 UTF-16 unit), a 3-byte rune (€, 1 unit) and
+// a 4-byte rune (😀, a surrogate pair of 2 units).
+const utf16Src = "a := \"é€\"\nb := \"😀\" + c\n"
+
+func TestPositionToOffset(t *testing.T) {
+	tests := []struct {
+		name string
+		pos  protocol.Position
+		want int
+	}{
+		{"start", protocol.Position{Line: 0, Character: 0}, 0},
+		{"before multi-byte", protocol.Position{Line: 0, Character: 6}, 6},
+		{"after 2-byte rune", protocol.Position{Line: 0, Character: 7}, 8},
+		{"after 3-byte rune", protocol.Position{Line: 0, Character: 8}, 11},
+		{"end of line", protocol.Position{Line: 0, Character: 9}, 12},
+		{"past end of line", protocol.Position{Line: 0, Character: 42}, 12},
+		{"second line", protocol.Position{Line: 1, Character: 0}, 13},
+		{"after surrogate pair", protocol.Position{Line: 1, Character: 8}, 23},
+		{"end of second line", protocol.Position{Line: 1, Character: 13}, 28},
+		{"end of file", protocol.Position{Line: 2, Character: 0}, len(utf16Src)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := positionToOffset([]byte(utf16Src), tt.pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("positionToOffset(%v) = %d, want %d", tt.pos, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := positionToOffset([]byte(utf16Src), protocol.Position{Line: 3}); err == nil {
+		t.Error("positionToOffset of a line out of range: want error")
+	}
+}
+
+func TestOffsetToPosition(t *testing.T) {
+	tests := []struct {
+		offset int
+		want   protocol.Position
+	}{
+		{0, protocol.Position{Line: 0, Character: 0}},
+		{8, protocol.Position{Line: 0, Character: 7}},
+		{11, protocol.Position{Line: 0, Character: 8}},
+		{12, protocol.Position{Line: 0, Character: 9}},
+		{13, protocol.Position{Line: 1, Character: 0}},
+		{23, protocol.Position{Line: 1, Character: 8}},
+		{28, protocol.Position{Line: 1, Character: 13}},
+		{len(utf16Src), protocol.Position{Line: 2, Character: 0}},
+	}
+	for _, tt := range tests {
+		got, err := offsetToPosition([]byte(utf16Src), tt.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("offsetToPosition(%d) = %v, want %v", tt.offset, got, tt.want)
+		}
+		// Round trip
+		if offset, _ := positionToOffset([]byte(utf16Src), got); offset != tt.offset {
+			t.Errorf("positionToOffset(offsetToPosition(%d)) = %d", tt.offset, offset)
+		}
+	}
+
+	if _, err := offsetToPosition([]byte(utf16Src), len(utf16Src)+1); err == nil {
+		t.Error("offsetToPosition of an offset out of range: want error")
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	rng := func(startLine, startChar, endLine, endChar uint32) *protocol.Range {
+		return &protocol.Range{
+			Start: protocol.Position{Line: startLine, Character: startChar},
+			End:   protocol.Position{Line: endLine, Character: endChar},
+		}
+	}
+	tests := []struct {
+		name    string
+		changes []ContentChange
+		want    string
+	}{
+		{
+			name:    "full content",
+			changes: []ContentChange{{Text: "x"}},
+			want:    "x",
+		},
+		{
+			name:    "replace multi-byte runes",
+			changes: []ContentChange{{Range: rng(0, 6, 0, 8), Text: "e"}},
+			want:    "a := \"e\"\nb := \"😀\" + c\n",
+		},
+		{
+			name:    "insert after surrogate pair",
+			changes: []ContentChange{{Range: rng(1, 8, 1, 8), Text: "!"}},
+			want:    "a := \"é€\"\nb := \"😀!\" + c\n",
+		},
+		{
+			name:    "delete surrogate pair",
+			changes: []ContentChange{{Range: rng(1, 6, 1, 8), Text: ""}},
+			want:    "a := \"é€\"\nb := \"\" + c\n",
+		},
+		{
+			name:    "across lines",
+			changes: []ContentChange{{Range: rng(0, 7, 1, 8), Text: "🎉"}},
+			want:    "a := \"é🎉\" + c\n",
+		},
+		{
+			name: "applied in order",
+			changes: []ContentChange{
+				{Range: rng(1, 12, 1, 13), Text: "ç"},
+				{Range: rng(1, 12, 1, 13), Text: "d"},
+				{Range: rng(0, 0, 0, 1), Text: "α"},
+			},
+			want: "α := \"é€\"\nb := \"😀\" + d\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &GnoFile{Src: []byte(utf16Src), Version: 1}
+			got, err := f.ApplyChanges(tt.changes, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got.Src) != tt.want {
+				t.Errorf("ApplyChanges() = %q, want %q", got.Src, tt.want)
+			}
+			if got.Version != 2 {
+				t.Errorf("ApplyChanges() version = %d, want 2", got.Version)
+			}
+			if string(f.Src) != utf16Src {
+				t.Errorf("ApplyChanges() modified the original file: %q", f.Src)
+			}
+		})
+	}
+
+	f := &GnoFile{Src: []byte(utf16Src)}
+	if _, err := f.ApplyChanges([]ContentChange{{Range: rng(0, 2, 0, 1)}}, 2); err == nil {
+		t.Error("ApplyChanges with an inverted range: want error")
+	}
+}
+
+func TestTokenRangeUTF16(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.gno")
+	content := "package a\n\nvar s = \"😀\" + t // é\n"
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, content, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// `t` in `"😀" + t`
+	bin := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.BinaryExpr)
+	got := nodeToRange(fset, bin.Y, nil)
+	want := protocol.Range{
+		Start: protocol.Position{Line: 2, Character: 15},
+		End:   protocol.Position{Line: 2, Character: 16},
+	}
+	if got != want {
+		t.Errorf("nodeToRange(t) = %v, want %v", got, want)
+	}
+}
+
+func TestTokenRangeOverlay(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.gno")
+	// The file on disk differs from the open one, which is parsed.
+	if err := os.WriteFile(filename, []byte("package a\n\nvar s = \"\" + t\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	content := "package a\n\nvar s = \"😀\" + t\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, content, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.BinaryExpr)
+	got := nodeToRange(fset, bin.Y, map[string][]byte{filename: []byte(content)})
+	want := protocol.Range{
+		Start: protocol.Position{Line: 2, Character: 15},
+		End:   protocol.Position{Line: 2, Character: 16},
+	}
+	if got != want {
+		t.Errorf("nodeToRange(t) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package lsp
+
+import (
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/gnolang/gnopls/internal/builtin"
+	"go.lsp.dev/protocol"
+)
+
+// snippetEscaper escapes the characters having a meaning in the snippet
+// syntax, e.g. the brace of `interface{}` in a placeholder.
+var snippetEscaper = strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`)
+
+// callSnippet returns the call of name with a placeholder per parameter,
+// e.g. `Set(${1:key string}, ${2:value interface{\}})`.
+func callSnippet(name string, params []string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteByte('(')
+	for i, p := range params {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("${" + strconv.Itoa(i+1) + ":" + snippetEscaper.Replace(p) + "}")
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// callInsertText returns the insert text of the completion of the function
+// name. Its parameters, from sig, are placeholders if the client supports
+// snippets. sig can be nil if unknown.
+func (s *server) callInsertText(name string, sig *types.Signature, from *types.Package) (string, protocol.InsertTextFormat) {
+	if !s.snippets || sig == nil || sig.Params().Len() == 0 {
+		return name + "()", protocol.InsertTextFormatPlainText
+	}
+	var params []string
+	for _, p := range signatureInformation(name, sig, from).Parameters {
+		params = append(params, p.Label)
+	}
+	return callSnippet(name, params), protocol.InsertTextFormatSnippet
+}
+
+// builtinItems returns the completion items of the builtins matching
+// prefix, with placeholders for the parameters of the functions if the
+// client supports snippets.
+func (s *server) builtinItems(prefix string) []protocol.CompletionItem {
+	items := builtin.GetCompletions(prefix)
+	if !s.snippets {
+		return items
+	}
+	res := make([]protocol.CompletionItem, 0, len(items))
+	for _, item := range items {
+		if item.Kind == protocol.CompletionItemKindFunction {
+			if sig := builtinSignature(item.Label); sig != nil && len(sig.Parameters) > 0 {
+				var params []string
+				for _, p := range sig.Parameters {
+					params = append(params, p.Label)
+				}
+				item.InsertText = callSnippet(item.Label, params)
+				item.InsertTextFormat = protocol.InsertTextFormatSnippet
+			}
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+// snippet is a keyword or statement snippet, proposed when its filter
+// matches the word being completed.
+type snippet struct {
+	label  string
+	filter string
+	body   string
+	// realm restricts the snippet to realm packages.
+	realm bool
+}
+
+// statementSnippets are proposed at the start of statements.
+var statementSnippets = []snippet{
+	{label: "for range", filter: "for", body: "for ${1:_}, ${2:v} := range ${3:x} {\n\t$0\n}"},
+	{label: "if err != nil", filter: "if", body: "if err != nil {\n\t${1:return err}\n}"},
+}
+
+// declSnippets are proposed at the start of top-level declarations.
+var declSnippets = []snippet{
+	{label: "func (r *T) Method()", filter: "func", body: "func (${1:r} *${2:T}) ${3:Method}($4) {\n\t$0\n}"},
+	{label: "func Render(path string) string", filter: "func", body: "func Render(path string) string {\n\t${0:return \"\"}\n}", realm: true},
+}
+
+// snippetItems returns the completion items of the snippets, matched
+// against their filter. They're only proposed once a prefix is written, and
+// if the client supports snippets.
+func (s *server) snippetItems(snippets []snippet, prefix string, realm bool) []protocol.CompletionItem {
+	if !s.snippets || prefix == "" {
+		return nil
+	}
+	var items []protocol.CompletionItem
+	for _, sn := range snippets {
+		if sn.realm && !realm {
+			continue
+		}
+		items = append(items, protocol.CompletionItem{
+			Label:            sn.label,
+			FilterText:       sn.filter,
+			InsertText:       sn.body,
+			InsertTextFormat: protocol.InsertTextFormatSnippet,
+			Kind:             protocol.CompletionItemKindSnippet,
+		})
+	}
+	return items
+}
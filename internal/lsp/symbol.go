@@ -0,0 +1,251 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+)
+
+func (s *server) DocumentSymbol(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.DocumentSymbolParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	uri := params.TextDocument.URI
+	file, ok := s.getSnapshot().Get(uri.Filename())
+	if !ok {
+		return reply(ctx, nil, errors.New("snapshot not found"))
+	}
+	// Files with syntax errors are outlined from their partial AST.
+	pgf, err := file.ParseGno(ctx)
+	if pgf == nil {
+		return reply(ctx, nil, err)
+	}
+
+	return reply(ctx, documentSymbols(pgf), nil)
+}
+
+// documentSymbols returns the outline of pgf: types with their fields and
+// methods, functions, and constants and variables, grouped as declared.
+func documentSymbols(pgf *ParsedGnoFile) []protocol.DocumentSymbol {
+	fset, overlay := pgf.Fset, pgf.overlay()
+	symbols := []protocol.DocumentSymbol{}
+	// index of the type symbols by name, to attach methods to them.
+	typeIndex := map[string]int{}
+
+	var methods []*ast.FuncDecl
+
+	for _, decl := range pgf.File.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				methods = append(methods, decl)
+				continue
+			}
+			sym := funcSymbol(fset, decl, overlay)
+			switch decl.Name.Name {
+			case "Render":
+				sym.Detail += " (realm render)"
+			case "init":
+				sym.Detail += " (package initializer)"
+			}
+			symbols = append(symbols, sym)
+
+		case *ast.GenDecl:
+			switch decl.Tok {
+			case token.TYPE:
+				for _, spec := range decl.Specs {
+					ts := spec.(*ast.TypeSpec)
+					sym := typeSymbol(fset, ts, overlay)
+					if !decl.Lparen.IsValid() {
+						sym.Range = nodeToRange(fset, decl, overlay)
+					}
+					typeIndex[ts.Name.Name] = len(symbols)
+					symbols = append(symbols, sym)
+				}
+			case token.CONST, token.VAR:
+				children := valueSymbols(fset, decl, overlay)
+				if len(children) == 0 {
+					continue
+				}
+				if !decl.Lparen.IsValid() {
+					symbols = append(symbols, children...)
+					continue
+				}
+				// Grouped declaration, i.e. `const ( ... )`
+				symbols = append(symbols, protocol.DocumentSymbol{
+					Name:           decl.Tok.String() + " (...)",
+					Kind:           children[0].Kind,
+					Range:          nodeToRange(fset, decl, overlay),
+					SelectionRange: tokenRange(fset, decl.TokPos, decl.TokPos+token.Pos(len(decl.Tok.String())), overlay),
+					Children:       children,
+				})
+			}
+		}
+	}
+
+	for _, fd := range methods {
+		sym := funcSymbol(fset, fd, overlay)
+		sym.Kind = protocol.SymbolKindMethod
+		if i, ok := typeIndex[receiverTypeName(fd)]; ok {
+			symbols[i].Children = append(symbols[i].Children, sym)
+			continue
+		}
+		// Receiver type declared in another file
+		sym.Name = "(" + types.ExprString(fd.Recv.List[0].Type) + ")." + sym.Name
+		symbols = append(symbols, sym)
+	}
+
+	return symbols
+}
+
+func typeSymbol(fset *token.FileSet, ts *ast.TypeSpec, overlay map[string][]byte) protocol.DocumentSymbol {
+	sym := protocol.DocumentSymbol{
+		Name:           ts.Name.Name,
+		Kind:           protocol.SymbolKindClass,
+		Range:          nodeToRange(fset, ts, overlay),
+		SelectionRange: nodeToRange(fset, ts.Name, overlay),
+	}
+	switch t := ts.Type.(type) {
+	case *ast.StructType:
+		sym.Kind = protocol.SymbolKindStruct
+		sym.Detail = "struct{...}"
+		for _, field := range t.Fields.List {
+			if len(field.Names) == 0 { // embedded field
+				sym.Children = append(sym.Children, protocol.DocumentSymbol{
+					Name:           types.ExprString(field.Type),
+					Detail:         types.ExprString(field.Type),
+					Kind:           protocol.SymbolKindField,
+					Range:          nodeToRange(fset, field, overlay),
+					SelectionRange: nodeToRange(fset, field.Type, overlay),
+				})
+				continue
+			}
+			for _, name := range field.Names {
+				sym.Children = append(sym.Children, protocol.DocumentSymbol{
+					Name:           name.Name,
+					Detail:         types.ExprString(field.Type),
+					Kind:           protocol.SymbolKindField,
+					Range:          nodeToRange(fset, field, overlay),
+					SelectionRange: nodeToRange(fset, name, overlay),
+				})
+			}
+		}
+	case *ast.InterfaceType:
+		sym.Kind = protocol.SymbolKindInterface
+		sym.Detail = "interface{...}"
+		for _, method := range t.Methods.List {
+			if len(method.Names) == 0 { // embedded interface
+				sym.Children = append(sym.Children, protocol.DocumentSymbol{
+					Name:           types.ExprString(method.Type),
+					Kind:           protocol.SymbolKindInterface,
+					Range:          nodeToRange(fset, method, overlay),
+					SelectionRange: nodeToRange(fset, method.Type, overlay),
+				})
+				continue
+			}
+			for _, name := range method.Names {
+				sym.Children = append(sym.Children, protocol.DocumentSymbol{
+					Name:           name.Name,
+					Detail:         types.ExprString(method.Type),
+					Kind:           protocol.SymbolKindMethod,
+					Range:          nodeToRange(fset, method, overlay),
+					SelectionRange: nodeToRange(fset, name, overlay),
+				})
+			}
+		}
+	default:
+		sym.Detail = types.ExprString(ts.Type)
+	}
+	return sym
+}
+
+func funcSymbol(fset *token.FileSet, fd *ast.FuncDecl, overlay map[string][]byte) protocol.DocumentSymbol {
+	return protocol.DocumentSymbol{
+		Name:           fd.Name.Name,
+		Detail:         types.ExprString(fd.Type),
+		Kind:           protocol.SymbolKindFunction,
+		Range:          nodeToRange(fset, fd, overlay),
+		SelectionRange: nodeToRange(fset, fd.Name, overlay),
+	}
+}
+
+// valueSymbols returns the symbols declared by a const or var declaration.
+func valueSymbols(fset *token.FileSet, decl *ast.GenDecl, overlay map[string][]byte) []protocol.DocumentSymbol {
+	kind := protocol.SymbolKindVariable
+	if decl.Tok == token.CONST {
+		kind = protocol.SymbolKindConstant
+	}
+	var symbols []protocol.DocumentSymbol
+	for _, spec := range decl.Specs {
+		vs := spec.(*ast.ValueSpec)
+		rng := nodeToRange(fset, vs, overlay)
+		if !decl.Lparen.IsValid() {
+			rng = nodeToRange(fset, decl, overlay)
+		}
+		for _, name := range vs.Names {
+			if name.Name == "_" {
+				continue
+			}
+			symbols = append(symbols, protocol.DocumentSymbol{
+				Name:           name.Name,
+				Detail:         valueSpecDetail(vs),
+				Kind:           kind,
+				Range:          rng,
+				SelectionRange: nodeToRange(fset, name, overlay),
+			})
+		}
+	}
+	return symbols
+}
+
+func valueSpecDetail(vs *ast.ValueSpec) string {
+	if vs.Type != nil {
+		return types.ExprString(vs.Type)
+	}
+	return ""
+}
+
+// receiverTypeName returns the name of the receiver type of a method,
+// without pointer nor type parameters.
+func receiverTypeName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return ""
+	}
+	t := fd.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch t := t.(type) {
+	case *ast.IndexExpr:
+		return types.ExprString(t.X)
+	case *ast.IndexListExpr:
+		return types.ExprString(t.X)
+	}
+	return types.ExprString(t)
+}
+
+// maxWorkspaceSymbols is the maximum number of symbols returned by a
+// workspace/symbol request.
+const maxWorkspaceSymbols = 100
+
+func (s *server) WorkspaceSymbol(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params protocol.WorkspaceSymbolParams
+	if err := json.Unmarshal(req.Params(), &params); err != nil {
+		return sendParseError(ctx, reply, err)
+	}
+
+	// Workspace packages change, so they are indexed on each request, from
+	// the ones loaded for the snapshot.
+	snapshot := s.getSnapshot()
+	index := NewSymbolIndex(s.workspacePackages(snapshot), snapshot.Overlay())
+	symbols := searchSymbols(params.Query, maxWorkspaceSymbols, index, s.symbolIndex)
+	return reply(ctx, symbols, nil)
+}
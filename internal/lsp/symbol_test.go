@@ -0,0 +1,35 @@
+package lsp
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestWorkspaceSymbol(t *testing.T) {
+	s, dir := newTestServer(t, map[string]string{
+		"gno.mod": "module gno.land/r/test/ws\n",
+		"ws.gno":  "package ws\n\nfunc Render(path string) string { return \"\" }\n",
+	})
+	// The open file differs from the one on disk.
+	src := "package ws\n\nvar s = \"😀\"; func Hello() {}\n"
+	file := testFile(t, s, dir, "ws.gno")
+	s.snapshot = s.snapshot.WithFile(&GnoFile{URI: file.URI, Src: []byte(src), Version: 2})
+
+	var symbols []protocol.SymbolInformation
+	testCall(t, s.WorkspaceSymbol, "workspace/symbol", protocol.WorkspaceSymbolParams{Query: "Hello"}, &symbols)
+	if len(symbols) != 1 {
+		t.Fatalf("got %d symbols, want 1: %v", len(symbols), symbols)
+	}
+	want := protocol.Location{
+		URI: getURI(filepath.Join(dir, "ws.gno")),
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 2, Character: 19},
+			End:   protocol.Position{Line: 2, Character: 24},
+		},
+	}
+	if got := symbols[0]; got.Name != "gno.land/r/test/ws.Hello" || got.Location != want {
+		t.Errorf("got %s at %v, want gno.land/r/test/ws.Hello at %v", got.Name, got.Location, want)
+	}
+}
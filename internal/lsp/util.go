@@ -1,12 +1,16 @@
 package lsp
 
 import (
+	"bytes"
 	"fmt"
-	"io"
+	"go/ast"
+	"go/token"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"go.lsp.dev/protocol"
 )
@@ -59,113 +63,142 @@ func ListGnoFiles(path string) ([]string, error) {
 	return files, nil
 }
 
-// GoToGnoFileName return gno file name from generated go file
-// If not a generated go file, return unchanged fname
-func GoToGnoFileName(fname string) string {
-	fname = strings.TrimSuffix(fname, ".gen_test.go")
-	fname = strings.TrimSuffix(fname, ".gen.go")
-	return fname
-}
-
-// copyDir copies the content of src to dst (not the src dir itself),
-// the paths have to be absolute to ensure consistent behavior.
-func copyDir(src, dst string) error {
-	if !filepath.IsAbs(src) || !filepath.IsAbs(dst) {
-		return fmt.Errorf("src or dst path not absolute, src: %s dst: %s", src, dst)
+func symbolToKind(symbol string) protocol.CompletionItemKind {
+	switch symbol {
+	case "const":
+		return protocol.CompletionItemKindConstant
+	case "func":
+		return protocol.CompletionItemKindFunction
+	case "type":
+		return protocol.CompletionItemKindClass
+	case "var":
+		return protocol.CompletionItemKindVariable
+	case "struct":
+		return protocol.CompletionItemKindStruct
+	case "interface":
+		return protocol.CompletionItemKindInterface
+	case "package":
+		return protocol.CompletionItemKindModule
+	default:
+		return protocol.CompletionItemKindValue
 	}
+}
 
-	entries, err := os.ReadDir(src)
-	if err != nil {
-		return fmt.Errorf("cannot read dir: %s", src)
+func symbolToSymbolKind(symbol string) protocol.SymbolKind {
+	switch symbol {
+	case "const":
+		return protocol.SymbolKindConstant
+	case "func":
+		return protocol.SymbolKindFunction
+	case "method":
+		return protocol.SymbolKindMethod
+	case "var":
+		return protocol.SymbolKindVariable
+	case "struct":
+		return protocol.SymbolKindStruct
+	case "interface":
+		return protocol.SymbolKindInterface
+	default:
+		return protocol.SymbolKindClass
 	}
+}
 
-	if err := os.MkdirAll(dst, 0o755); err != nil {
-		return fmt.Errorf("failed to create directory: '%s', error: '%w'", dst, err)
+// nodeToLocation returns the location of node n, the fset must be the one
+// used to parse n. The files of overlay are read instead of their content on
+// disk, see lspPosition.
+func nodeToLocation(fset *token.FileSet, n ast.Node, overlay map[string][]byte) protocol.Location {
+	return protocol.Location{
+		URI:   getURI(fset.Position(n.Pos()).Filename),
+		Range: nodeToRange(fset, n, overlay),
 	}
+}
 
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
+// nodeToRange returns the range of node n, the fset must be the one used to
+// parse n. The files of overlay are read instead of their content on disk,
+// see lspPosition.
+func nodeToRange(fset *token.FileSet, n ast.Node, overlay map[string][]byte) protocol.Range {
+	return tokenRange(fset, n.Pos(), n.End(), overlay)
+}
 
-		if entry.Type().IsDir() {
-			copyDir(srcPath, dstPath)
-		} else if entry.Type().IsRegular() {
-			copyFile(srcPath, dstPath)
-		}
+// tokenRange returns the range between the start and end positions. The
+// files of overlay are read instead of their content on disk, see
+// lspPosition.
+func tokenRange(fset *token.FileSet, startPos, endPos token.Pos, overlay map[string][]byte) protocol.Range {
+	return protocol.Range{
+		Start: lspPosition(fset.Position(startPos), overlay),
+		End:   lspPosition(fset.Position(endPos), overlay),
 	}
-
-	return nil
 }
 
-// copyFile copies the file from src to dst, the paths have
-// to be absolute to ensure consistent behavior.
-func copyFile(src, dst string) error {
-	if !filepath.IsAbs(src) || !filepath.IsAbs(dst) {
-		return fmt.Errorf("src or dst path not absolute, src: %s dst: %s", src, dst)
+// lspPosition returns the LSP position of p, whose character is expressed in
+// UTF-16 code units. The content of the file of p is needed to convert its
+// column, which counts bytes: it's read from overlay, the files open in the
+// snapshot p comes from, or else from disk. If it's unknown or doesn't match
+// p, the column is kept as is.
+func lspPosition(p token.Position, overlay map[string][]byte) protocol.Position {
+	pos := protocol.Position{
+		Line:      uint32(max(p.Line-1, 0)),
+		Character: uint32(max(p.Column-1, 0)),
 	}
-
-	// verify if it's regular flile
-	srcStat, err := os.Stat(src)
-	if err != nil {
-		return fmt.Errorf("cannot copy file: %w", err)
+	if !p.IsValid() || p.Filename == "" {
+		return pos
 	}
-	if !srcStat.Mode().IsRegular() {
-		return fmt.Errorf("%s not a regular file", src)
+	src, ok := overlay[p.Filename]
+	if !ok {
+		src = diskSources.get(p.Filename)
 	}
-
-	// create dst file
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return err
+	lineStart := p.Offset - (p.Column - 1)
+	if lineStart < 0 || p.Offset > len(src) || (lineStart > 0 && src[lineStart-1] != '\n') ||
+		bytes.IndexByte(src[lineStart:p.Offset], '\n') >= 0 {
+		return pos
 	}
-	defer dstFile.Close()
+	pos.Character = utf16Len(src[lineStart:p.Offset])
+	return pos
+}
 
-	// open src file
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer srcFile.Close()
+// maxDiskSources is the number of files kept by diskSources.
+const maxDiskSources = 64
 
-	// copy srcFile -> dstFile
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		return err
-	}
+// diskSources caches the content of the files read from disk to convert
+// their positions. The files saved or closed by the client are dropped.
+var diskSources = &sourceCache{src: map[string][]byte{}}
 
-	return nil
+// sourceCache caches the content of the files last read from disk, up to
+// maxDiskSources files.
+type sourceCache struct {
+	mu  sync.Mutex
+	src map[string][]byte
+	// names holds the cached files, from the least recently read.
+	names []string
 }
 
-func posToRange(line int, span []int) *protocol.Range {
-	return &protocol.Range{
-		Start: protocol.Position{
-			Line:      uint32(line - 1),
-			Character: uint32(span[0] - 1),
-		},
-		End: protocol.Position{
-			Line:      uint32(line - 1),
-			Character: uint32(span[1] - 1),
-		},
+// get returns the content of filename, or nil if it can't be read.
+func (c *sourceCache) get(filename string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if src, ok := c.src[filename]; ok {
+		return src
+	}
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	if len(c.names) == maxDiskSources {
+		delete(c.src, c.names[0])
+		c.names = c.names[1:]
 	}
+	c.src[filename] = src
+	c.names = append(c.names, filename)
+	return src
 }
 
-func symbolToKind(symbol string) protocol.CompletionItemKind {
-	switch symbol {
-	case "const":
-		return protocol.CompletionItemKindConstant
-	case "func":
-		return protocol.CompletionItemKindFunction
-	case "type":
-		return protocol.CompletionItemKindClass
-	case "var":
-		return protocol.CompletionItemKindVariable
-	case "struct":
-		return protocol.CompletionItemKindStruct
-	case "interface":
-		return protocol.CompletionItemKindInterface
-	case "package":
-		return protocol.CompletionItemKindModule
-	default:
-		return protocol.CompletionItemKindValue
+// forget drops the cached content of filename, after it was written.
+func (c *sourceCache) forget(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.src[filename]; !ok {
+		return
 	}
+	delete(c.src, filename)
+	c.names = slices.DeleteFunc(c.names, func(name string) bool { return name == filename })
 }
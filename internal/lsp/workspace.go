@@ -0,0 +1,132 @@
+package lsp
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gnolang/gno/gnovm/pkg/gnomod"
+)
+
+// workspaceIndex indexes the gno packages found under the workspace folders.
+// It's built once and reused until a file event invalidates it.
+type workspaceIndex struct {
+	// dirs holds the directories of the packages by import path.
+	dirs map[string]string
+	// pkgs holds the packages sorted by import path, as seen by the
+	// snapshot pkgsSnapshotID. They're loaded on demand.
+	pkgs           []*Package
+	pkgsLoaded     bool
+	pkgsSnapshotID uint64
+}
+
+// workspace returns the index of the workspace, building it if needed.
+// s.workspaceMu must be held.
+func (s *server) workspace() *workspaceIndex {
+	if s.workspaceIndex == nil {
+		s.workspaceIndex = &workspaceIndex{dirs: listWorkspacePackageDirs(s.workspaceFolders)}
+	}
+	return s.workspaceIndex
+}
+
+// invalidateWorkspace drops the index of the workspace, after files were
+// created, saved or deleted.
+func (s *server) invalidateWorkspace() {
+	s.workspaceMu.Lock()
+	defer s.workspaceMu.Unlock()
+	s.workspaceIndex = nil
+}
+
+// workspacePackageDirs returns the gno packages found under the workspace
+// folders, indexed by import path. Packages without a `gno.mod` in their
+// directory or in one of its parents are skipped. The result is shared and
+// must not be modified.
+func (s *server) workspacePackageDirs() map[string]string {
+	s.workspaceMu.Lock()
+	defer s.workspaceMu.Unlock()
+	return s.workspace().dirs
+}
+
+// listWorkspacePackageDirs walks folders to find the packages returned by
+// workspacePackageDirs.
+func listWorkspacePackageDirs(folders []string) map[string]string {
+	res := map[string]string{}
+	dirs, err := ListGnoPackages(folders)
+	if err != nil {
+		return res
+	}
+	for _, dir := range dirs {
+		path := importPathFromGnoMod(dir)
+		if path == "" {
+			continue
+		}
+		res[path] = dir
+	}
+	return res
+}
+
+// workspacePackages returns the gno packages of the workspace, sorted by
+// import path. The files open in snapshot are read from it. The packages
+// are reused while snapshot doesn't change, so they must not be modified.
+func (s *server) workspacePackages(snapshot *Snapshot) []*Package {
+	s.workspaceMu.Lock()
+	defer s.workspaceMu.Unlock()
+	ws := s.workspace()
+	if ws.pkgsLoaded && ws.pkgsSnapshotID == snapshot.ID() {
+		// Clipped, so that appending to the result doesn't modify ws.
+		return slices.Clip(ws.pkgs)
+	}
+
+	overlay := snapshot.Overlay()
+	var pkgs []*Package
+	for path, dir := range ws.dirs {
+		pkg, err := packageFromDir(dir, false, overlay)
+		if err != nil {
+			continue
+		}
+		pkg.ImportPath = path
+		pkgs = append(pkgs, pkg)
+	}
+	slices.SortFunc(pkgs, func(a, b *Package) int {
+		return strings.Compare(a.ImportPath, b.ImportPath)
+	})
+	ws.pkgs, ws.pkgsLoaded, ws.pkgsSnapshotID = pkgs, true, snapshot.ID()
+	return slices.Clip(pkgs)
+}
+
+// newTypeCheck returns a TypeCheck which resolves the workspace packages,
+// and reads the files open in snapshot instead of their content on disk.
+func (s *server) newTypeCheck(snapshot *Snapshot) (*TypeCheck, *error) {
+	tc, errs := NewTypeCheck()
+	for path, dir := range s.workspacePackageDirs() {
+		tc.dirs[path] = dir
+	}
+	tc.overlay = snapshot.Overlay()
+	return tc, errs
+}
+
+// importPathFromGnoMod returns the import path of the package located in
+// dir, based on the module path of the closest `gno.mod` and the position
+// of dir relative to it.
+func importPathFromGnoMod(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	root, err := gnomod.FindRootDir(dir)
+	if err != nil {
+		return ""
+	}
+	gm, err := gnomod.ParseAt(root)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return ""
+	}
+	if rel == "." {
+		return gm.Module.Mod.Path
+	}
+	return gm.Module.Mod.Path + "/" + filepath.ToSlash(rel)
+}